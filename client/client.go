@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	openapi "github.com/nhoag/sumologic-search-job-client-go"
+)
+
+// Config holds the settings needed to talk to the Search Job API.
+type Config struct {
+	AccessID  string
+	AccessKey string
+	// Endpoint is the full API URL, e.g. https://api.sumologic.com/api. It
+	// takes precedence over Deployment and is meant for proxies and test
+	// servers.
+	Endpoint string
+	// Deployment selects the endpoint from Deployments.
+	Deployment string
+	// DefaultHost is the API host name used when neither Endpoint nor
+	// Deployment is set.
+	DefaultHost string
+	// Retry overrides DefaultRetryPolicy when set.
+	Retry *RetryPolicy
+	// RateLimit overrides DefaultRateLimit when set.
+	RateLimit *RateLimit
+	// CookieDir, if set, is where the session cookies of each search job are
+	// saved so that later processes keep talking to the same API node.
+	CookieDir string
+	// OnRedirect, if set, is called when the API redirects the client to
+	// another endpoint, usually because the account lives in a different
+	// deployment. deployment is empty for unknown endpoints.
+	OnRedirect func(endpoint string, deployment string)
+}
+
+// Client is a Search Job API client. It is safe for concurrent use.
+type Client struct {
+	api        *openapi.APIClient
+	auth       openapi.BasicAuth
+	retry      RetryPolicy
+	limiter    *rateLimiter
+	jar        *jobCookieJar
+	onRedirect func(endpoint string, deployment string)
+
+	mu       sync.RWMutex
+	endpoint string
+}
+
+// maxRedirects bounds how many deployment redirects a single call follows.
+const maxRedirects = 3
+
+// New builds a Client from the provided configuration.
+func New(config Config) (*Client, error) {
+	endpoint, err := resolveEndpoint(config)
+	if err != nil {
+		return nil, err
+	}
+	configuration := openapi.NewConfiguration()
+	// The endpoint is supplied per request so it can change after a redirect.
+	configuration.Servers = openapi.ServerConfigurations{
+		{
+			URL:       "{endpoint}",
+			Variables: map[string]openapi.ServerVariable{"endpoint": {DefaultValue: endpoint}},
+		},
+	}
+	jar := newJobCookieJar(config.CookieDir)
+	configuration.HTTPClient = &http.Client{
+		Jar: jar,
+		// Redirects are handled in withRetry: the default policy would turn
+		// a POST into a GET and drop the credentials on the way.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	retry := DefaultRetryPolicy
+	if config.Retry != nil {
+		retry = *config.Retry
+	}
+	rateLimit := DefaultRateLimit
+	if config.RateLimit != nil {
+		rateLimit = *config.RateLimit
+	}
+	return &Client{
+		api: openapi.NewAPIClient(configuration),
+		auth: openapi.BasicAuth{
+			UserName: config.AccessID,
+			Password: config.AccessKey,
+		},
+		retry:      retry,
+		limiter:    newRateLimiter(rateLimit),
+		jar:        jar,
+		onRedirect: config.OnRedirect,
+		endpoint:   endpoint,
+	}, nil
+}
+
+// Endpoint returns the API endpoint currently in use.
+func (c *Client) Endpoint() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.endpoint
+}
+
+func (c *Client) setEndpoint(endpoint string) {
+	c.mu.Lock()
+	c.endpoint = endpoint
+	c.mu.Unlock()
+	if c.onRedirect != nil {
+		c.onRedirect(endpoint, EndpointDeployment(endpoint))
+	}
+}
+
+// requestContext attaches the client credentials and endpoint to ctx.
+func (c *Client) requestContext(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, openapi.ContextBasicAuth, c.auth)
+	return context.WithValue(ctx, openapi.ContextServerVariables, map[string]string{"endpoint": c.Endpoint()})
+}
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// jobPath matches the search job ID in API request paths.
+var jobPath = regexp.MustCompile(`/v1/search/jobs/([^/]+)`)
+
+// storedCookie is the on-disk form of a cookie saved for a search job.
+type storedCookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// jobCookieJar is an http.CookieJar that keeps a separate jar per search job,
+// so that the session affinity cookies returned when a job is created are sent
+// with every later request for that job and only that job. When dir is set,
+// each job's cookies are persisted there so other processes can reuse them.
+type jobCookieJar struct {
+	dir    string
+	mu     sync.Mutex
+	shared http.CookieJar
+	jobs   map[string]http.CookieJar
+}
+
+func newJobCookieJar(dir string) *jobCookieJar {
+	shared, _ := cookiejar.New(nil)
+	return &jobCookieJar{
+		dir:    dir,
+		shared: shared,
+		jobs:   make(map[string]http.CookieJar),
+	}
+}
+
+func jobIdFromURL(u *url.URL) string {
+	m := jobPath.FindStringSubmatch(u.Path)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// SetCookies implements http.CookieJar.
+func (j *jobCookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	jobId := jobIdFromURL(u)
+	if len(jobId) == 0 {
+		j.shared.SetCookies(u, cookies)
+		return
+	}
+	j.setJobCookies(u, jobId, cookies)
+}
+
+// Cookies implements http.CookieJar.
+func (j *jobCookieJar) Cookies(u *url.URL) []*http.Cookie {
+	jobId := jobIdFromURL(u)
+	if len(jobId) == 0 {
+		return j.shared.Cookies(u)
+	}
+	return j.jobJar(u, jobId).Cookies(u)
+}
+
+func (j *jobCookieJar) setJobCookies(u *url.URL, jobId string, cookies []*http.Cookie) {
+	if len(cookies) == 0 {
+		return
+	}
+	jar := j.jobJar(u, jobId)
+	jar.SetCookies(u, cookies)
+	j.save(u, jobId, jar)
+}
+
+// jobJar returns the jar for jobId, loading saved cookies on first use.
+func (j *jobCookieJar) jobJar(u *url.URL, jobId string) http.CookieJar {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if jar, ok := j.jobs[jobId]; ok {
+		return jar
+	}
+	jar, _ := cookiejar.New(nil)
+	j.jobs[jobId] = jar
+	if cookies := j.load(jobId); len(cookies) > 0 {
+		jar.SetCookies(u, cookies)
+	}
+	return jar
+}
+
+func (j *jobCookieJar) path(jobId string) string {
+	return filepath.Join(j.dir, jobId+".json")
+}
+
+func (j *jobCookieJar) load(jobId string) []*http.Cookie {
+	if len(j.dir) == 0 {
+		return nil
+	}
+	content, err := os.ReadFile(j.path(jobId))
+	if err != nil {
+		return nil
+	}
+	var stored []storedCookie
+	if err := json.Unmarshal(content, &stored); err != nil {
+		return nil
+	}
+	cookies := make([]*http.Cookie, 0, len(stored))
+	for _, c := range stored {
+		cookies = append(cookies, &http.Cookie{Name: c.Name, Value: c.Value, Path: "/"})
+	}
+	return cookies
+}
+
+// save writes the cookies held for jobId. Failing to persist cookies only
+// costs session affinity in later runs, so errors are ignored.
+func (j *jobCookieJar) save(u *url.URL, jobId string, jar http.CookieJar) {
+	if len(j.dir) == 0 {
+		return
+	}
+	var stored []storedCookie
+	for _, c := range jar.Cookies(u) {
+		stored = append(stored, storedCookie{Name: c.Name, Value: c.Value})
+	}
+	content, err := json.Marshal(stored)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(j.dir, 0700); err != nil {
+		return
+	}
+	os.WriteFile(j.path(jobId), content, 0600)
+}
+
+// forget drops the cookies held for jobId, including any saved copy.
+func (j *jobCookieJar) forget(jobId string) {
+	j.mu.Lock()
+	delete(j.jobs, jobId)
+	j.mu.Unlock()
+	if len(j.dir) > 0 {
+		os.Remove(j.path(jobId))
+	}
+}
+
+// jobURL returns the API URL of a search job, which the job's cookies are
+// scoped to.
+func (c *Client) jobURL(jobId string) *url.URL {
+	u, err := url.Parse(c.Endpoint() + "/v1/search/jobs/" + url.PathEscape(jobId))
+	if err != nil {
+		return &url.URL{Path: "/v1/search/jobs/" + jobId}
+	}
+	return u
+}
+
+// JobCookies returns the session affinity cookies held for a search job.
+func (c *Client) JobCookies(jobId string) []*http.Cookie {
+	u := c.jobURL(jobId)
+	return c.jar.jobJar(u, jobId).Cookies(u)
+}
+
+// SetJobCookies restores the session affinity cookies of a search job, such
+// as ones saved by another process.
+func (c *Client) SetJobCookies(jobId string, cookies []*http.Cookie) {
+	c.jar.setJobCookies(c.jobURL(jobId), jobId, cookies)
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/nhoag/sumo-search-job-cli/fakeserver"
+)
+
+// cookieServer is a fake API server that records the cookies sent with each
+// request, keyed by method and path.
+type cookieServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	cookies map[string]string
+}
+
+func newCookieServer(t *testing.T) *cookieServer {
+	t.Helper()
+	server := fakeserver.New(fakeserver.Config{Fixture: fakeserver.Fixture{Scenarios: []fakeserver.Scenario{{}}}})
+	s := &cookieServer{cookies: make(map[string]string)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.cookies[r.Method+" "+r.URL.Path] = r.Header.Get("Cookie")
+		s.mu.Unlock()
+		server.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// sent returns the Cookie header of the last request for method and path.
+func (s *cookieServer) sent(method string, path string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cookies[method+" "+path]
+}
+
+func TestJobCookiesAcrossClients(t *testing.T) {
+	ctx := context.Background()
+	srv := newCookieServer(t)
+	dir := filepath.Join(t.TempDir(), "cookies")
+	newClient := func(cookieDir string) *Client {
+		c, err := New(Config{Endpoint: srv.URL + "/api", RateLimit: &RateLimit{}, CookieDir: cookieDir})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	definition := SearchJobDefinition{Query: "error", From: "2024-01-01T00:00:00", To: "2024-01-01T01:00:00", TimeZone: "UTC"}
+
+	// jobCreate: the API pins each job to a node with a cookie.
+	creator := newClient(dir)
+	_, first, err := creator.CreateSearchJob(ctx, definition)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, second, err := creator.CreateSearchJob(ctx, definition)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, first+".json")); err != nil {
+		t.Fatalf("cookies of search job %s were not saved: %v", first, err)
+	}
+
+	// jobStatusCheck, in another process: each job's cookie is sent with
+	// the requests for that job only.
+	checker := newClient(dir)
+	for _, jobId := range []string{first, second} {
+		if _, err := checker.GetSearchJobStatus(ctx, jobId); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := srv.sent(http.MethodGet, "/api/v1/search/jobs/"+jobId), "fakeserver-node="+jobId; got != want {
+			t.Errorf("status request for %s sent cookies %q, want %q", jobId, got, want)
+		}
+	}
+
+	// Without the cookie directory the cookies stay with the creator.
+	if _, err := newClient("").GetSearchJobStatus(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.sent(http.MethodGet, "/api/v1/search/jobs/"+first); got != "" {
+		t.Errorf("client without cookie directory sent cookies %q", got)
+	}
+
+	// Deleting a job forgets its cookies.
+	if err := checker.DeleteSearchJob(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := srv.sent(http.MethodDelete, "/api/v1/search/jobs/"+first), "fakeserver-node="+first; got != want {
+		t.Errorf("delete request sent cookies %q, want %q", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, first+".json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("cookies of deleted search job %s are still saved: %v", first, err)
+	}
+	if cookies := newClient(dir).JobCookies(second); len(cookies) != 1 || cookies[0].Value != second {
+		t.Errorf("JobCookies(%s) = %v, want the node cookie", second, cookies)
+	}
+}
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Deployments maps each Sumo Logic deployment to its Search Job API endpoint.
+var Deployments = map[string]string{
+	"au":  "https://api.au.sumologic.com/api",
+	"ca":  "https://api.ca.sumologic.com/api",
+	"de":  "https://api.de.sumologic.com/api",
+	"eu":  "https://api.eu.sumologic.com/api",
+	"fed": "https://api.fed.sumologic.com/api",
+	"in":  "https://api.in.sumologic.com/api",
+	"jp":  "https://api.jp.sumologic.com/api",
+	"us1": "https://api.sumologic.com/api",
+	"us2": "https://api.us2.sumologic.com/api",
+}
+
+// DeploymentNames returns the known deployments in alphabetical order.
+func DeploymentNames() []string {
+	names := make([]string, 0, len(Deployments))
+	for name := range Deployments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DeploymentEndpoint returns the API endpoint for a deployment name.
+func DeploymentEndpoint(deployment string) (string, error) {
+	endpoint, ok := Deployments[strings.ToLower(deployment)]
+	if !ok {
+		return "", fmt.Errorf("unknown deployment %q (valid: %s)", deployment, strings.Join(DeploymentNames(), ", "))
+	}
+	return endpoint, nil
+}
+
+// EndpointDeployment returns the deployment serving endpoint, or an empty
+// string when the endpoint is not a known Sumo Logic API host.
+func EndpointDeployment(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return ""
+	}
+	for name, known := range Deployments {
+		if k, err := url.Parse(known); err == nil && strings.EqualFold(k.Host, u.Host) {
+			return name
+		}
+	}
+	return ""
+}
+
+// resolveEndpoint picks the API endpoint from an explicit URL, a deployment
+// name or a bare host name, in that order.
+func resolveEndpoint(config Config) (string, error) {
+	if len(config.Endpoint) > 0 {
+		u, err := url.Parse(config.Endpoint)
+		if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+			return "", fmt.Errorf("invalid endpoint %q: expected a full URL such as https://api.sumologic.com/api", config.Endpoint)
+		}
+		return strings.TrimSuffix(config.Endpoint, "/"), nil
+	}
+	if len(config.Deployment) > 0 {
+		return DeploymentEndpoint(config.Deployment)
+	}
+	if len(config.DefaultHost) > 0 {
+		return "https://" + config.DefaultHost + "/api", nil
+	}
+	return DeploymentEndpoint("us1")
+}
+
+// redirectEndpoint returns the API endpoint a redirect response points at. The
+// Location header carries the full request URL, so everything before the
+// versioned path is the new endpoint.
+func redirectEndpoint(resp *http.Response) (string, bool) {
+	if resp == nil {
+		return "", false
+	}
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return "", false
+	}
+	location, err := resp.Location()
+	if err != nil {
+		return "", false
+	}
+	endpoint := location.Scheme + "://" + location.Host
+	if i := strings.Index(location.Path, "/v1/"); i >= 0 {
+		endpoint += location.Path[:i]
+	} else {
+		endpoint += "/api"
+	}
+	return endpoint, true
+}
@@ -0,0 +1,132 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nhoag/sumo-search-job-cli/fakeserver"
+)
+
+func TestRedirectEndpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		location string
+		want     string
+		ok       bool
+	}{
+		{"moved permanently", http.StatusMovedPermanently, "https://api.eu.sumologic.com/api/v1/search/jobs", "https://api.eu.sumologic.com/api", true},
+		{"found", http.StatusFound, "https://api.us2.sumologic.com/api/v1/search/jobs/ABC", "https://api.us2.sumologic.com/api", true},
+		{"temporary", http.StatusTemporaryRedirect, "https://proxy.example.com/sumo/api/v1/search/jobs", "https://proxy.example.com/sumo/api", true},
+		{"permanent without versioned path", http.StatusPermanentRedirect, "https://api.de.sumologic.com/", "https://api.de.sumologic.com/api", true},
+		{"not a redirect", http.StatusOK, "https://api.eu.sumologic.com/api/v1/search/jobs", "", false},
+		{"no location", http.StatusMovedPermanently, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}, Request: &http.Request{}}
+			if len(tt.location) > 0 {
+				resp.Header.Set("Location", tt.location)
+			}
+			got, ok := redirectEndpoint(resp)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("redirectEndpoint() = %q, %t, want %q, %t", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+// redirectServer answers every request with a 301 to the same path on
+// target, as the API does for accounts of another deployment.
+func redirectServer(t *testing.T, target func() string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Redirect(w, r, target()+r.URL.Path, http.StatusMovedPermanently)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestRedirectToAnotherHost(t *testing.T) {
+	ctx := context.Background()
+	var mu sync.Mutex
+	var authorization []string
+	api := fakeserver.New(fakeserver.Config{Fixture: fakeserver.Fixture{Scenarios: []fakeserver.Scenario{{}}}})
+	deployment := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		authorization = append(authorization, r.Header.Get("Authorization"))
+		mu.Unlock()
+		api.ServeHTTP(w, r)
+	}))
+	t.Cleanup(deployment.Close)
+	// The account's deployment is reached by another host name.
+	target := strings.Replace(deployment.URL, "127.0.0.1", "localhost", 1)
+	origin, originRequests := redirectServer(t, func() string { return target })
+
+	var redirects []string
+	c, err := New(Config{
+		AccessID:   "id",
+		AccessKey:  "key",
+		Endpoint:   origin.URL + "/api",
+		RateLimit:  &RateLimit{},
+		OnRedirect: func(endpoint string, deployment string) { redirects = append(redirects, endpoint) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, jobId, err := c.CreateSearchJob(ctx, SearchJobDefinition{Query: "error", From: "2024-01-01T00:00:00", To: "2024-01-01T01:00:00", TimeZone: "UTC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetSearchJobStatus(ctx, jobId); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := c.Endpoint(), target+"/api"; got != want {
+		t.Errorf("Endpoint() = %s, want %s", got, want)
+	}
+	if len(redirects) != 1 || redirects[0] != target+"/api" {
+		t.Errorf("OnRedirect calls %v, want one for %s/api", redirects, target)
+	}
+	// Later requests go straight to the new endpoint.
+	if n := originRequests.Load(); n != 1 {
+		t.Errorf("original endpoint got %d requests, want 1", n)
+	}
+	// The POST and its credentials survive the redirect.
+	if ids := api.JobIds(); len(ids) != 1 || ids[0] != jobId {
+		t.Errorf("search jobs %v, want %s", ids, jobId)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(authorization) != 2 {
+		t.Errorf("new endpoint got %d requests, want 2", len(authorization))
+	}
+	for i, header := range authorization {
+		if !strings.HasPrefix(header, "Basic ") {
+			t.Errorf("request %d to the new endpoint has Authorization %q", i+1, header)
+		}
+	}
+}
+
+func TestRedirectLoop(t *testing.T) {
+	var first, second *httptest.Server
+	first, firstRequests := redirectServer(t, func() string { return second.URL })
+	second, secondRequests := redirectServer(t, func() string { return first.URL })
+	c, err := New(Config{Endpoint: first.URL + "/api", RateLimit: &RateLimit{}, Retry: &RetryPolicy{MaxAttempts: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetSearchJobStatus(context.Background(), "ABC"); err == nil {
+		t.Fatal("GetSearchJobStatus() succeeded in a redirect loop")
+	}
+	if n := firstRequests.Load() + secondRequests.Load(); n != maxRedirects+1 {
+		t.Errorf("got %d requests, want %d", n, maxRedirects+1)
+	}
+}
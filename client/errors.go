@@ -0,0 +1,95 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	openapi "github.com/nhoag/sumologic-search-job-client-go"
+)
+
+// APIError is returned when the Search Job API responds with an error status.
+type APIError struct {
+	Op         string
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s: HTTP %d", e.Op, e.StatusCode)
+	if len(e.Code) > 0 {
+		msg += " " + e.Code
+	}
+	if len(e.Message) > 0 {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// IsNotFound reports whether err is an APIError for a missing search job.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// wrapError converts an error from the generated client into an APIError when
+// an HTTP response is available, or annotates it with the operation otherwise.
+func wrapError(op string, resp *http.Response, err error) error {
+	if err == nil {
+		return nil
+	}
+	if resp == nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	apiErr := &APIError{
+		Op:         op,
+		StatusCode: resp.StatusCode,
+		Message:    err.Error(),
+	}
+	var openapiErr *openapi.GenericOpenAPIError
+	var statusErr *statusError
+	if errors.As(err, &openapiErr) {
+		if model, ok := openapiErr.Model().(openapi.ErrorResponse); ok && len(model.Errors) > 0 {
+			apiErr.Code = model.Errors[0].GetCode()
+			apiErr.Message = model.Errors[0].GetMessage()
+		} else {
+			apiErr.Code, apiErr.Message = flatError(openapiErr.Body(), apiErr.Message)
+		}
+	} else if errors.As(err, &statusErr) {
+		var model openapi.ErrorResponse
+		if json.Unmarshal(statusErr.body, &model) == nil && len(model.Errors) > 0 {
+			apiErr.Code = model.Errors[0].GetCode()
+			apiErr.Message = model.Errors[0].GetMessage()
+		} else {
+			apiErr.Code, apiErr.Message = flatError(statusErr.body, apiErr.Message)
+		}
+	}
+	return apiErr
+}
+
+// flatError decodes the flat {"code": ..., "message": ...} object the Search
+// Job API reports most errors as, rather than the errors list described by
+// the spec. The fallback message is returned when body has no code.
+func flatError(body []byte, fallback string) (string, string) {
+	var flat struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &flat) == nil && len(flat.Code) > 0 {
+		return flat.Code, flat.Message
+	}
+	return "", fallback
+}
+
+// statusError is an error status returned by a request the client built
+// itself rather than through the generated client.
+type statusError struct {
+	status string
+	body   []byte
+}
+
+func (e *statusError) Error() string {
+	return e.status
+}
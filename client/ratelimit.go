@@ -0,0 +1,141 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/nhoag/sumo-search-job-cli/internal/filelock"
+)
+
+// RateLimit configures the token bucket every API call passes through.
+type RateLimit struct {
+	// Rate is the sustained number of requests per second. Values of zero or
+	// less disable rate limiting.
+	Rate float64
+	// Burst is the bucket size, i.e. how many requests may be sent at once.
+	Burst int
+	// StateFile, if set, holds the bucket state so that concurrent processes
+	// on the same machine share one budget. Access is serialized with an
+	// exclusive lock on the file.
+	StateFile string
+}
+
+// DefaultRateLimit is used when a Config does not specify a rate limit.
+var DefaultRateLimit = RateLimit{
+	Rate:  4,
+	Burst: 10,
+}
+
+// bucketState is the persisted token bucket.
+type bucketState struct {
+	Tokens  float64 `json:"tokens"`
+	Updated int64   `json:"updated"`
+}
+
+// rateLimiter is a token bucket, optionally shared between processes through
+// a state file.
+type rateLimiter struct {
+	config RateLimit
+	mu     sync.Mutex
+	state  bucketState
+}
+
+func newRateLimiter(config RateLimit) *rateLimiter {
+	if config.Rate <= 0 {
+		return nil
+	}
+	if config.Burst < 1 {
+		config.Burst = 1
+	}
+	return &rateLimiter{
+		config: config,
+		state:  bucketState{Tokens: float64(config.Burst), Updated: time.Now().UnixNano()},
+	}
+}
+
+// Wait blocks until a token is available or ctx is done.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	for {
+		delay, err := l.take()
+		if err != nil {
+			return err
+		}
+		if delay <= 0 {
+			return nil
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// take consumes a token if one is available, otherwise it returns how long to
+// wait before one will be.
+func (l *rateLimiter) take() (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.config.StateFile) == 0 {
+		return l.consume(&l.state, time.Now()), nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.config.StateFile), 0700); err != nil {
+		return 0, fmt.Errorf("rate limit state: %w", err)
+	}
+	file, err := os.OpenFile(l.config.StateFile, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return 0, fmt.Errorf("rate limit state: %w", err)
+	}
+	defer file.Close()
+	if err := filelock.Lock(file); err != nil {
+		return 0, fmt.Errorf("rate limit state: %w", err)
+	}
+	defer filelock.Unlock(file)
+
+	state := bucketState{Tokens: float64(l.config.Burst), Updated: time.Now().UnixNano()}
+	if err := json.NewDecoder(file).Decode(&state); err != nil {
+		// An empty or corrupt file starts a fresh bucket.
+		state = bucketState{Tokens: float64(l.config.Burst), Updated: time.Now().UnixNano()}
+	}
+	delay := l.consume(&state, time.Now())
+	content, err := json.Marshal(state)
+	if err != nil {
+		return 0, err
+	}
+	if err := file.Truncate(0); err != nil {
+		return 0, fmt.Errorf("rate limit state: %w", err)
+	}
+	if _, err := file.WriteAt(content, 0); err != nil {
+		return 0, fmt.Errorf("rate limit state: %w", err)
+	}
+	return delay, nil
+}
+
+// consume refills state up to now and takes a token when possible.
+func (l *rateLimiter) consume(state *bucketState, now time.Time) time.Duration {
+	elapsed := now.Sub(time.Unix(0, state.Updated)).Seconds()
+	if elapsed > 0 {
+		state.Tokens += elapsed * l.config.Rate
+	}
+	if state.Tokens > float64(l.config.Burst) {
+		state.Tokens = float64(l.config.Burst)
+	}
+	state.Updated = now.UnixNano()
+	if state.Tokens >= 1 {
+		state.Tokens--
+		return 0
+	}
+	return time.Duration((1 - state.Tokens) / l.config.Rate * float64(time.Second))
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterConsume(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	l := newRateLimiter(RateLimit{Rate: 2, Burst: 3})
+	tests := []struct {
+		name   string
+		tokens float64
+		after  time.Duration
+		delay  time.Duration
+		left   float64
+	}{
+		{name: "token available", tokens: 3, delay: 0, left: 2},
+		{name: "empty bucket", tokens: 0, delay: 500 * time.Millisecond, left: 0},
+		{name: "partly refilled", tokens: 0, after: 250 * time.Millisecond, delay: 250 * time.Millisecond, left: 0.5},
+		{name: "refilled one token", tokens: 0.5, after: 250 * time.Millisecond, delay: 0, left: 0},
+		// The bucket never holds more than Burst tokens.
+		{name: "refill capped at burst", tokens: 1, after: time.Hour, delay: 0, left: 2},
+		// A clock that went backwards doesn't drain the bucket.
+		{name: "clock went back", tokens: 1.5, after: -time.Second, delay: 0, left: 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := bucketState{Tokens: tt.tokens, Updated: start.UnixNano()}
+			now := start.Add(tt.after)
+			if delay := l.consume(&state, now); delay != tt.delay {
+				t.Errorf("delay %s, want %s", delay, tt.delay)
+			}
+			if state.Tokens != tt.left {
+				t.Errorf("%g tokens left, want %g", state.Tokens, tt.left)
+			}
+			if state.Updated != now.UnixNano() {
+				t.Errorf("bucket updated at %d, want %d", state.Updated, now.UnixNano())
+			}
+		})
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	// --rate-limit 0 configures a zero rate.
+	for _, rate := range []float64{0, -1} {
+		if l := newRateLimiter(RateLimit{Rate: rate, Burst: 10}); l != nil {
+			t.Errorf("rate %g: got a limiter, want none", rate)
+		}
+	}
+	c, err := New(Config{Endpoint: "http://127.0.0.1/api", RateLimit: &RateLimit{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.limiter != nil {
+		t.Fatal("client with a zero rate has a limiter")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// A nil limiter never blocks, not even on a cancelled context.
+	for i := 0; i < 100; i++ {
+		if err := c.limiter.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	l := newRateLimiter(RateLimit{Rate: 20, Burst: 1})
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first token is in the bucket, the others come 50ms apart.
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 requests at 20/s with a burst of 1 took %s, want at least 100ms", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	slow := newRateLimiter(RateLimit{Rate: 0.1, Burst: 1})
+	if err := slow.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := slow.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestRateLimiterSharedState(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "sumo", "ratelimit.json")
+	// Two limiters stand for two processes using the same access key.
+	config := RateLimit{Rate: 0.01, Burst: 3, StateFile: stateFile}
+	first, second := newRateLimiter(config), newRateLimiter(config)
+	for i, l := range []*rateLimiter{first, second, first} {
+		delay, err := l.take()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if delay != 0 {
+			t.Fatalf("request %d waits %s, want a token from the shared burst", i+1, delay)
+		}
+	}
+	// The burst is used up for both.
+	for i, l := range []*rateLimiter{second, first} {
+		delay, err := l.take()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if delay < 90*time.Second {
+			t.Errorf("limiter %d waits %s once the shared burst is used, want about 100s", i+1, delay)
+		}
+	}
+
+	content, err := os.ReadFile(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state bucketState
+	if err := json.Unmarshal(content, &state); err != nil {
+		t.Fatal(err)
+	}
+	if state.Tokens >= 1 {
+		t.Errorf("state file holds %g tokens, want less than one", state.Tokens)
+	}
+
+	// A corrupt state file starts a fresh bucket.
+	if err := os.WriteFile(stateFile, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if delay, err := newRateLimiter(config).take(); err != nil || delay != 0 {
+		t.Errorf("take() with a corrupt state file = %s, %v, want a token", delay, err)
+	}
+}
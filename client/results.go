@@ -0,0 +1,88 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Field describes a column of a messages or records page.
+type Field struct {
+	Name      string `json:"name"`
+	FieldType string `json:"fieldType,omitempty"`
+	KeyField  bool   `json:"keyField,omitempty"`
+}
+
+// Row is a single message or record. Every field the query produced is kept,
+// including ones the generated API models do not know about.
+type Row map[string]string
+
+// UnmarshalJSON accepts non-string values and stores their JSON text.
+func (r *Row) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	row := make(Row, len(raw))
+	for key, value := range raw {
+		var s string
+		if err := json.Unmarshal(value, &s); err == nil {
+			row[key] = s
+			continue
+		}
+		if string(value) == "null" {
+			row[key] = ""
+			continue
+		}
+		row[key] = string(value)
+	}
+	*r = row
+	return nil
+}
+
+// Item is the envelope the API wraps around each row.
+type Item struct {
+	Map Row `json:"map"`
+}
+
+// MessagesPage is a page of messages found by a search job.
+type MessagesPage struct {
+	Fields   []Field `json:"fields"`
+	Messages []Item  `json:"messages"`
+}
+
+// Rows returns the messages of the page.
+func (p *MessagesPage) Rows() []Row {
+	return rows(p.Messages)
+}
+
+// RecordsPage is a page of aggregate records produced by a search job.
+type RecordsPage struct {
+	Fields  []Field `json:"fields"`
+	Records []Item  `json:"records"`
+}
+
+// Rows returns the records of the page.
+func (p *RecordsPage) Rows() []Row {
+	return rows(p.Records)
+}
+
+func rows(items []Item) []Row {
+	result := make([]Row, 0, len(items))
+	for _, item := range items {
+		result = append(result, item.Map)
+	}
+	return result
+}
+
+// decodeBody decodes the body of a successful response into v. The generated
+// client leaves a rewound copy of the body on the response.
+func decodeBody(op string, resp *http.Response, v interface{}) error {
+	if resp == nil || resp.Body == nil {
+		return fmt.Errorf("%s: empty response", op)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s: decoding response: %w", op, err)
+	}
+	return nil
+}
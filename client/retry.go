@@ -0,0 +1,148 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how API calls are retried after a 429 or 5xx response.
+// Creating a search job is only retried after a 429 or a refused connection.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// Values below 1 disable retries.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry. It doubles on every
+	// subsequent retry up to MaxDelay.
+	BaseDelay time.Duration
+	// MaxDelay caps the computed backoff delay and any Retry-After value.
+	MaxDelay time.Duration
+	// Jitter is the fraction (0 to 1) of each delay that is randomized.
+	Jitter float64
+	// OnRetry, if set, is called before sleeping ahead of each retry.
+	OnRetry func(op string, attempt int, delay time.Duration, err error)
+}
+
+// DefaultRetryPolicy is used when a Config does not specify a policy.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   time.Second,
+	MaxDelay:    time.Minute,
+	Jitter:      0.2,
+}
+
+// retryable reports whether a failed idempotent call should be retried.
+func retryable(resp *http.Response, err error) bool {
+	if resp == nil {
+		return false
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// retryableCreate reports whether a failed create should be retried. A 5xx or
+// a timeout may come after the server has created the job, so a retry could
+// start a duplicate that is never tracked or deleted. Only a 429 or a refused
+// connection show that the request was not processed.
+func retryableCreate(resp *http.Response, err error) bool {
+	if resp != nil {
+		return resp.StatusCode == http.StatusTooManyRequests
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// backoff returns the delay before retry number attempt (starting at 1),
+// preferring the server's Retry-After header when present.
+func (p RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	if delay, ok := retryAfter(resp); ok {
+		if p.MaxDelay > 0 && delay > p.MaxDelay {
+			delay = p.MaxDelay
+		}
+		return delay
+	}
+	delay := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay > p.MaxDelay {
+			delay = p.MaxDelay
+			break
+		}
+	}
+	if p.Jitter > 0 {
+		spread := float64(delay) * p.Jitter
+		delay = time.Duration(float64(delay) - spread + rand.Float64()*2*spread)
+	}
+	return delay
+}
+
+// retryAfter parses the Retry-After header as either delay seconds or an
+// HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	value := resp.Header.Get("Retry-After")
+	if len(value) == 0 {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// withRetry runs call until it succeeds, fails with a non-retryable error, the
+// attempts are exhausted or ctx is done. Every attempt waits on the client rate
+// limiter first and receives a fresh request context, so that redirects to
+// another deployment take effect. The returned error is already wrapped.
+func (c *Client) withRetry(ctx context.Context, op string, call func(context.Context) (*http.Response, error)) (*http.Response, error) {
+	return c.withRetryIf(ctx, op, retryable, call)
+}
+
+// withRetryIf is withRetry with the failures to retry chosen by shouldRetry.
+func (c *Client) withRetryIf(ctx context.Context, op string, shouldRetry func(*http.Response, error) bool, call func(context.Context) (*http.Response, error)) (*http.Response, error) {
+	policy := c.retry
+	redirects := 0
+	for attempt := 1; ; attempt++ {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		resp, err := call(c.requestContext(ctx))
+		if err == nil {
+			return resp, nil
+		}
+		if endpoint, ok := redirectEndpoint(resp); ok && redirects < maxRedirects && endpoint != c.Endpoint() {
+			c.setEndpoint(endpoint)
+			redirects++
+			attempt--
+			continue
+		}
+		retry := shouldRetry(resp, err)
+		err = wrapError(op, resp, err)
+		if !retry || attempt >= policy.MaxAttempts {
+			return resp, err
+		}
+		delay := policy.backoff(attempt, resp)
+		if policy.OnRetry != nil {
+			policy.OnRetry(op, attempt, delay, err)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
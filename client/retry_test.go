@@ -0,0 +1,198 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client for endpoint without rate limiting.
+func newTestClient(t *testing.T, endpoint string, policy RetryPolicy) *Client {
+	t.Helper()
+	c, err := New(Config{Endpoint: endpoint, Retry: &policy, RateLimit: &RateLimit{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// statusServer answers status requests with the given codes in turn, then
+// with a finished job.
+func statusServer(t *testing.T, codes []int, header http.Header) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+		w.Header().Set("Content-Type", "application/json")
+		if n <= len(codes) {
+			for key, values := range header {
+				w.Header()[key] = values
+			}
+			w.WriteHeader(codes[n-1])
+			w.Write([]byte(`{"status":500,"id":"X","code":"test.error","message":"test error"}`))
+			return
+		}
+		w.Write([]byte(`{"state":"DONE GATHERING RESULTS","messageCount":0,"recordCount":0}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestBackoffRetryAfter(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Minute, Jitter: 0.5}
+	tests := []struct {
+		name       string
+		retryAfter string
+		want       time.Duration
+	}{
+		{"seconds", "7", 7 * time.Second},
+		{"capped", "3600", time.Minute},
+		{"zero", "0", 0},
+		{"past date", "Mon, 02 Jan 2006 15:04:05 GMT", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{"Retry-After": {tt.retryAfter}}}
+			if got := policy.backoff(1, resp); got != tt.want {
+				t.Errorf("backoff() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoffCap(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 40 * time.Millisecond}
+	want := []time.Duration{10, 20, 40, 40, 40}
+	for i, w := range want {
+		if got := policy.backoff(i+1, nil); got != w*time.Millisecond {
+			t.Errorf("backoff(%d) = %s, want %s", i+1, got, w*time.Millisecond)
+		}
+	}
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	srv, requests := statusServer(t, []int{http.StatusTooManyRequests}, http.Header{"Retry-After": {"1"}})
+	var delays []time.Duration
+	c := newTestClient(t, srv.URL, RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    time.Minute,
+		OnRetry: func(op string, attempt int, delay time.Duration, err error) {
+			delays = append(delays, delay)
+		},
+	})
+	if _, err := c.GetSearchJobStatus(context.Background(), "JOB"); err != nil {
+		t.Fatal(err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+	if len(delays) != 1 || delays[0] != time.Second {
+		t.Errorf("delays = %v, want [1s]", delays)
+	}
+}
+
+func TestRetryMaxAttempts(t *testing.T) {
+	srv, requests := statusServer(t, []int{503, 503, 503, 503}, nil)
+	c := newTestClient(t, srv.URL, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	_, err := c.GetSearchJobStatus(context.Background(), "JOB")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("err = %v, want an HTTP 503 APIError", err)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+}
+
+func TestRetryContextCancelled(t *testing.T) {
+	srv, requests := statusServer(t, []int{503, 503}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	c := newTestClient(t, srv.URL, RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   time.Hour,
+		MaxDelay:    time.Hour,
+		OnRetry: func(op string, attempt int, delay time.Duration, err error) {
+			cancel()
+		},
+	})
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.GetSearchJobStatus(ctx, "JOB")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("err = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("retry did not stop when the context was cancelled")
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
+
+func TestCreateSearchJobRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     int
+		requests int32
+		wantErr  bool
+	}{
+		{"too many requests", http.StatusTooManyRequests, 2, false},
+		{"bad gateway", http.StatusBadGateway, 1, true},
+		{"gateway timeout", http.StatusGatewayTimeout, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if requests.Add(1) == 1 {
+					w.WriteHeader(tt.code)
+					return
+				}
+				w.Header().Set("Location", "http://"+r.Host+"/v1/search/jobs/JOB")
+				w.WriteHeader(http.StatusAccepted)
+			}))
+			defer srv.Close()
+			c := newTestClient(t, srv.URL, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+			_, jobId, err := c.CreateSearchJob(context.Background(), SearchJobDefinition{Query: "*"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && jobId != "JOB" {
+				t.Errorf("jobId = %q, want JOB", jobId)
+			}
+			if got := requests.Load(); got != tt.requests {
+				t.Errorf("requests = %d, want %d", got, tt.requests)
+			}
+		})
+	}
+}
+
+func TestCreateSearchJobRetriesRefusedConnection(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	endpoint := srv.URL
+	srv.Close()
+	attempts := 0
+	c := newTestClient(t, endpoint, RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    time.Millisecond,
+		OnRetry: func(op string, attempt int, delay time.Duration, err error) {
+			attempts = attempt
+		},
+	})
+	if _, _, err := c.CreateSearchJob(context.Background(), SearchJobDefinition{Query: "*"}); err == nil {
+		t.Fatal("CreateSearchJob succeeded against a closed server")
+	}
+	if attempts != 2 {
+		t.Errorf("retries = %d, want 2", attempts)
+	}
+}
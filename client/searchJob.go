@@ -1,109 +1,170 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
-	"os"
-	"regexp"
 	"strings"
 
 	openapi "github.com/nhoag/sumologic-search-job-client-go"
-	"github.com/spf13/viper"
 )
 
-func getClient() *openapi.APIClient {
-	configuration := openapi.NewConfiguration()
-	// @todo: Update endpoint resolution to be more robust
-	endpoint := getEndpoint(configuration.Servers)
-	if len(endpoint) == 0 {
-		endpoint = viper.GetString("default_host")
-	}
-	configuration.Host = endpoint
-	return openapi.NewAPIClient(configuration)
+// SearchJobDefinition is the body of a create search job request. Unlike
+// openapi.SearchJobDefinition it covers every field the API accepts.
+type SearchJobDefinition struct {
+	Query    string `json:"query"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	TimeZone string `json:"timeZone"`
+	// ByReceiptTime searches by receipt time instead of message time.
+	ByReceiptTime bool `json:"byReceiptTime"`
+	// AutoParsingMode is "performance" (the default) or "intelligent", which
+	// runs field extraction on JSON messages.
+	AutoParsingMode string `json:"autoParsingMode,omitempty"`
 }
 
-func getEndpoint(servers openapi.ServerConfigurations) string {
-	serverConfig := getServerConfig(servers)
-	m := regexp.MustCompile(`[^http(s)?//:][a-z0-9.-]+[^/api]`)
-	return m.FindString(serverConfig.URL)
-}
-
-func getServerConfig(servers openapi.ServerConfigurations) openapi.ServerConfiguration {
-	deployment := viper.GetString("deployment")
-	for _, server := range servers {
-		if strings.Contains(server.Description, strings.ToUpper(deployment)) {
-			return server
-		}
-	}
-	return openapi.ServerConfiguration{}
-}
-
-func getContext() context.Context {
-	return context.WithValue(context.Background(), openapi.ContextBasicAuth, openapi.BasicAuth{
-		UserName: viper.GetString("accessId"),
-		Password: viper.GetString("accessKey"),
+// CreateSearchJob starts a search job and returns its location and ID.
+func (c *Client) CreateSearchJob(ctx context.Context, searchJob SearchJobDefinition) (*url.URL, string, error) {
+	// The generated client would drop the fields its model lacks, so the
+	// request is built here.
+	body, err := json.Marshal(searchJob)
+	if err != nil {
+		return nil, "", fmt.Errorf("CreateSearchJob: %w", err)
+	}
+	resp, err := c.withRetryIf(ctx, "CreateSearchJob", retryableCreate, func(ctx context.Context) (*http.Response, error) {
+		return c.post(ctx, "/v1/search/jobs", body)
 	})
-}
-
-func CreateSearchJob(searchJob openapi.SearchJobDefinition) (*url.URL, string) {
-	request := getClient().DefaultApi.CreateSearchJob(getContext()).SearchJobDefinition(searchJob)
-	resp, err := request.Execute()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error when calling `DefaultApi.CreateSearchJob``: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Full HTTP response: %v\n", resp)
-		os.Exit(1)
+		return nil, "", err
 	}
 	location, err := resp.Location()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error when retrieving Location header: %v\n", err)
-		os.Exit(1)
+		return nil, "", fmt.Errorf("CreateSearchJob: retrieving Location header: %w", err)
 	}
 
 	locationArray := strings.Split(location.String(), "/")
 	jobId := locationArray[len(locationArray)-1]
-	return location, jobId
+	// Sumo Logic pins a job to the node that created it through these cookies.
+	c.jar.setJobCookies(location, jobId, resp.Cookies())
+	return location, jobId, nil
 }
 
-func DeleteSearchJob(jobId string) {
-	request := getClient().DefaultApi.DeleteSearchJob(getContext(), jobId)
-	resp, err := request.Execute()
+// DeleteSearchJob deletes the search job with the given ID.
+func (c *Client) DeleteSearchJob(ctx context.Context, jobId string) error {
+	_, err := c.withRetry(ctx, "DeleteSearchJob", func(ctx context.Context) (*http.Response, error) {
+		return c.api.DefaultApi.DeleteSearchJob(ctx, jobId).Execute()
+	})
+	if err == nil || IsNotFound(err) {
+		c.jar.forget(jobId)
+	}
+	return err
+}
+
+// GetSearchJobStatus returns the current state of the search job.
+func (c *Client) GetSearchJobStatus(ctx context.Context, jobId string) (*openapi.SearchJobState, error) {
+	var status *openapi.SearchJobState
+	_, err := c.withRetry(ctx, "GetSearchJobStatus", func(ctx context.Context) (resp *http.Response, err error) {
+		status, resp, err = c.api.DefaultApi.GetSearchJobStatus(ctx, jobId).Execute()
+		return resp, err
+	})
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error when calling `DefaultApi.DeleteSearchJob``: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Full HTTP response: %v\n", resp)
-		os.Exit(1)
+		return nil, err
 	}
+	return status, nil
+}
+
+// HistogramBucket is the number of messages found in a slice of the search
+// window.
+type HistogramBucket struct {
+	// StartTimestamp is the start of the bucket in epoch milliseconds.
+	StartTimestamp int64 `json:"startTimestamp"`
+	// Length is the width of the bucket in milliseconds.
+	Length int64 `json:"length"`
+	Count  int64 `json:"count"`
 }
 
-func GetSearchJobStatus(jobId string) *openapi.SearchJobState {
-	request := getClient().DefaultApi.GetSearchJobStatus(getContext(), jobId)
-	status, resp, err := request.Execute()
+// GetSearchJobHistogram returns the status of a search job along with its
+// histogram buckets. The generated model stores bucket start times as
+// float32, which can't hold epoch milliseconds, so the buckets are decoded
+// again from the response body.
+func (c *Client) GetSearchJobHistogram(ctx context.Context, jobId string) (*openapi.SearchJobState, []HistogramBucket, error) {
+	var status *openapi.SearchJobState
+	resp, err := c.withRetry(ctx, "GetSearchJobStatus", func(ctx context.Context) (resp *http.Response, err error) {
+		status, resp, err = c.api.DefaultApi.GetSearchJobStatus(ctx, jobId).Execute()
+		return resp, err
+	})
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error when calling `DefaultApi.GetSearchJobStatus``: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Full HTTP response: %v\n", resp)
-		os.Exit(1)
+		return nil, nil, err
 	}
-	return status
+	var histogram struct {
+		HistogramBuckets []HistogramBucket `json:"histogramBuckets"`
+	}
+	if err := decodeBody("GetSearchJobStatus", resp, &histogram); err != nil {
+		return nil, nil, err
+	}
+	return status, histogram.HistogramBuckets, nil
 }
 
-func GetSearchJobMessages(jobId string, limit int32, offset int32) *openapi.SearchJobMessages {
-	request := getClient().DefaultApi.GetSearchJobMessages(getContext(), jobId).Offset(offset).Limit(limit)
-	messages, resp, err := request.Execute()
+// GetSearchJobMessages returns a page of messages found by the search job.
+func (c *Client) GetSearchJobMessages(ctx context.Context, jobId string, limit int32, offset int32) (*MessagesPage, error) {
+	resp, err := c.withRetry(ctx, "GetSearchJobMessages", func(ctx context.Context) (*http.Response, error) {
+		_, resp, err := c.api.DefaultApi.GetSearchJobMessages(ctx, jobId).Offset(offset).Limit(limit).Execute()
+		return resp, err
+	})
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error when calling `DefaultApi.DeleteSearchJob``: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Full HTTP response: %v\n", resp)
-		os.Exit(1)
+		return nil, err
+	}
+	var messages MessagesPage
+	if err := decodeBody("GetSearchJobMessages", resp, &messages); err != nil {
+		return nil, err
 	}
-	return messages
+	return &messages, nil
 }
 
-func GetSearchJobRecords(jobId string, limit int32, offset int32) *openapi.SearchJobRecords {
-	request := getClient().DefaultApi.GetSearchJobRecords(getContext(), jobId).Offset(offset).Limit(limit)
-	records, resp, err := request.Execute()
+// GetSearchJobRecords returns a page of aggregate records produced by the search job.
+func (c *Client) GetSearchJobRecords(ctx context.Context, jobId string, limit int32, offset int32) (*RecordsPage, error) {
+	resp, err := c.withRetry(ctx, "GetSearchJobRecords", func(ctx context.Context) (*http.Response, error) {
+		_, resp, err := c.api.DefaultApi.GetSearchJobRecords(ctx, jobId).Offset(offset).Limit(limit).Execute()
+		return resp, err
+	})
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error when calling `DefaultApi.DeleteSearchJob``: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Full HTTP response: %v\n", resp)
-		os.Exit(1)
+		return nil, err
+	}
+	var records RecordsPage
+	if err := decodeBody("GetSearchJobRecords", resp, &records); err != nil {
+		return nil, err
+	}
+	return &records, nil
+}
+
+// post sends a JSON request to the API the way the generated client does.
+// Error statuses are returned as a *statusError for wrapError.
+func (c *Client) post(ctx context.Context, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint()+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	config := c.api.GetConfig()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", config.UserAgent)
+	req.SetBasicAuth(c.auth.UserName, c.auth.Password)
+	resp, err := config.HTTPClient.Do(req)
+	if err != nil {
+		return resp, err
+	}
+	content, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(content))
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode >= 300 {
+		return resp, &statusError{status: resp.Status, body: content}
 	}
-	return records
+	return resp, nil
 }
@@ -0,0 +1,301 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+
+	"github.com/nhoag/sumo-search-job-cli/credentials"
+)
+
+// passphraseEnv holds the passphrase of the encrypted credentials file for
+// non-interactive use.
+const passphraseEnv = "SUMO_CREDENTIALS_PASSPHRASE"
+
+// CredentialSources lists the values accepted for credential_source.
+var CredentialSources = []string{"config", "keyring", "file"}
+
+var (
+	BackendOpt  string
+	AccessIDOpt string
+)
+
+// authCmd represents the auth command
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Manage Sumo Logic credentials",
+	Long: `The auth command stores access keys outside the config file. Where
+	credentials come from is selected per profile: credential_process runs an
+	external command that prints them as JSON, and credential_source reads
+	them from the desktop keyring or a passphrase-encrypted file. Without
+	either, the plaintext accessId and accessKey settings are used.`,
+}
+
+// authLoginCmd represents the auth login command
+var authLoginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Store an access key in the keyring or an encrypted file",
+	Long: `The login command prompts for an access ID and key, stores them for
+	the active profile in the chosen backend, and sets credential_source in
+	the config file so that later commands use them.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		QuietOpt, _ = cmd.Flags().GetBool("quiet")
+		VerboseOpt, _ = cmd.Flags().GetBool("verbose")
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tSTART\tauth login\n", time.Now().UnixNano())
+		}
+		exitOnError(executeAuthLogin(cmd, args))
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tEND\tauth login\n", time.Now().UnixNano())
+		}
+	},
+}
+
+func executeAuthLogin(cmd *cobra.Command, args []string) error {
+	backend := BackendOpt
+	if len(backend) == 0 {
+		backend = defaultCredentialBackend()
+	}
+	store, err := credentialStore(backend)
+	if err != nil {
+		return err
+	}
+
+	input := bufio.NewReader(os.Stdin)
+	accessID := AccessIDOpt
+	if len(accessID) == 0 {
+		if accessID, err = prompt(input, "Access ID: ", false); err != nil {
+			return err
+		}
+	}
+	accessKey, err := prompt(input, "Access Key: ", true)
+	if err != nil {
+		return err
+	}
+	creds := credentials.Credentials{AccessID: accessID, AccessKey: accessKey}
+	if err := store.Save(cmd.Context(), creds); err != nil {
+		return err
+	}
+
+	profile := credentialProfile()
+	keys := []string{"credential_source"}
+	if len(viper.GetString("profile")) > 0 {
+		keys = []string{"profiles", profile, "credential_source"}
+	}
+	if path := viper.ConfigFileUsed(); len(path) > 0 {
+		if err := setConfigValue(path, keys, backend); err != nil {
+			return err
+		}
+	} else if !QuietOpt {
+		fmt.Fprintf(os.Stderr, "No config file found; set %s: %s to use the stored credentials\n", strings.Join(keys, "."), backend)
+	}
+	if !QuietOpt {
+		fmt.Fprintf(os.Stderr, "Stored credentials for profile %s in the %s backend\n", profile, backend)
+		if viper.InConfig("accesskey") || viper.InConfig("profiles."+profile+".accesskey") {
+			fmt.Fprintf(os.Stderr, "The config file still contains a plaintext accessKey; remove it\n")
+		}
+	}
+	return nil
+}
+
+// resolveCredentials returns the access ID and key of the active profile,
+// from credential_process, the credential_source backend, or the plaintext
+// accessId and accessKey settings.
+func resolveCredentials(ctx context.Context) (credentials.Credentials, error) {
+	if command := viper.GetString("credential_process"); len(command) > 0 {
+		return credentials.Process{Command: command}.Retrieve(ctx)
+	}
+	source := viper.GetString("credential_source")
+	if len(source) == 0 || source == "config" {
+		return credentials.Credentials{
+			AccessID:  viper.GetString("accessId"),
+			AccessKey: viper.GetString("accessKey"),
+		}, nil
+	}
+	store, err := credentialStore(source)
+	if err != nil {
+		return credentials.Credentials{}, err
+	}
+	creds, err := store.Retrieve(ctx)
+	if errors.Is(err, credentials.ErrNotFound) {
+		return credentials.Credentials{}, fmt.Errorf("%w (run 'sumo auth login' to store them)", err)
+	}
+	return creds, err
+}
+
+// credentialStore returns the named credentials backend for the active
+// profile.
+func credentialStore(backend string) (credentials.Store, error) {
+	switch backend {
+	case "keyring":
+		return credentials.Keyring{Profile: credentialProfile()}, nil
+	case "file":
+		return credentials.File{
+			Path:          credentialsFile(),
+			Profile:       credentialProfile(),
+			Passphrase:    readPassphrase,
+			NewPassphrase: readNewPassphrase,
+		}, nil
+	}
+	return nil, fmt.Errorf("invalid credential source %q (valid: %s)", backend, strings.Join(CredentialSources, ", "))
+}
+
+// credentialProfile is the name credentials are stored under.
+func credentialProfile() string {
+	if profile := strings.ToLower(viper.GetString("profile")); len(profile) > 0 {
+		return profile
+	}
+	return "default"
+}
+
+// defaultCredentialBackend prefers the configured source, then the keyring
+// when secret-tool is installed, then the encrypted file.
+func defaultCredentialBackend() string {
+	if source := viper.GetString("credential_source"); len(source) > 0 && source != "config" {
+		return source
+	}
+	if _, err := exec.LookPath("secret-tool"); err == nil {
+		return "keyring"
+	}
+	return "file"
+}
+
+// credentialsFile returns the path of the encrypted credentials file.
+func credentialsFile() string {
+	if path := viper.GetString("credentials_file"); len(path) > 0 {
+		return path
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ".sumo-credentials"
+	}
+	return filepath.Join(configDir, "sumo-search-job-cli", "credentials.json")
+}
+
+// readPassphrase returns the credentials file passphrase from the
+// environment, or prompts for it once per run.
+func readPassphrase() ([]byte, error) {
+	if passphrase := os.Getenv(passphraseEnv); len(passphrase) > 0 {
+		return []byte(passphrase), nil
+	}
+	if cachedPassphrase != nil {
+		return cachedPassphrase, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, fmt.Errorf("the credentials file is encrypted; set %s or run interactively", passphraseEnv)
+	}
+	passphrase, err := readPassword("Credentials passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	cachedPassphrase = passphrase
+	return passphrase, nil
+}
+
+// readNewPassphrase returns the passphrase of a credentials file that is
+// about to be created. Typed passphrases are asked for twice, since a typo
+// would lock the credentials away.
+func readNewPassphrase() ([]byte, error) {
+	if passphrase := os.Getenv(passphraseEnv); len(passphrase) > 0 {
+		return []byte(passphrase), nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, fmt.Errorf("the credentials file is encrypted; set %s or run interactively", passphraseEnv)
+	}
+	passphrase, err := confirmPassphrase(readPassword)
+	if err != nil {
+		return nil, err
+	}
+	cachedPassphrase = passphrase
+	return passphrase, nil
+}
+
+// confirmPassphrase reads a new passphrase and its confirmation with read.
+func confirmPassphrase(read func(label string) ([]byte, error)) ([]byte, error) {
+	passphrase, err := read("New credentials passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	confirmation, err := read("Repeat the passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(passphrase, confirmation) {
+		return nil, fmt.Errorf("the passphrases don't match")
+	}
+	return passphrase, nil
+}
+
+// readPassword prompts for a non-empty password on the terminal.
+func readPassword(label string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, label)
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+	return passphrase, nil
+}
+
+var cachedPassphrase []byte
+
+// prompt reads a line from the terminal, without echo when secret is set.
+// Input that is not a terminal is read line by line without a prompt.
+func prompt(input *bufio.Reader, label string, secret bool) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := input.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			if err != nil && err != io.EOF {
+				return "", err
+			}
+			return "", fmt.Errorf("no value given for %s", strings.TrimSuffix(label, ": "))
+		}
+		return line, nil
+	}
+	fmt.Fprint(os.Stderr, label)
+	var line string
+	if secret {
+		content, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		line = string(content)
+	} else {
+		content, err := input.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		line = content
+	}
+	line = strings.TrimSpace(line)
+	if len(line) == 0 {
+		return "", fmt.Errorf("no value given for %s", strings.TrimSuffix(label, ": "))
+	}
+	return line, nil
+}
+
+func init() {
+	rootCmd.AddCommand(authCmd)
+	authCmd.AddCommand(authLoginCmd)
+	authLoginCmd.Flags().StringVar(&BackendOpt, "backend", "", "Where to store the credentials: keyring or file (default is credential_source, then keyring if secret-tool is installed, then file)")
+	authLoginCmd.Flags().StringVar(&AccessIDOpt, "access-id", "", "Access ID (prompted for if not given)")
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestConfirmPassphrase(t *testing.T) {
+	tests := []struct {
+		name    string
+		inputs  []string
+		want    string
+		wantErr string
+	}{
+		{name: "matching", inputs: []string{"secret", "secret"}, want: "secret"},
+		{name: "mismatch", inputs: []string{"secret", "secert"}, wantErr: "the passphrases don't match"},
+		{name: "read error", inputs: []string{"secret"}, wantErr: "no input"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var labels []string
+			got, err := confirmPassphrase(func(label string) ([]byte, error) {
+				labels = append(labels, label)
+				if len(labels) > len(tt.inputs) {
+					return nil, errors.New("no input")
+				}
+				return []byte(tt.inputs[len(labels)-1]), nil
+			})
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("confirmPassphrase() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("confirmPassphrase() = %q, want %q", got, tt.want)
+			}
+			if len(labels) != 2 {
+				t.Errorf("prompted %d times, want twice", len(labels))
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nhoag/sumo-search-job-cli/client"
+)
+
+const checkpointVersion = 1
+
+var (
+	CheckpointOpt string
+	OutputFileOpt string
+)
+
+// checkpoint records the progress of an export by jobResultsGet, after
+// every page written, so that a failed export can be resumed.
+type checkpoint struct {
+	Version  int    `json:"version"`
+	JobID    string `json:"jobId"`
+	Endpoint string `json:"endpoint"`
+	// Cookies pin the job to the API node that holds it.
+	Cookies []checkpointCookie `json:"cookies,omitempty"`
+
+	Format         string      `json:"format"`
+	OutputFile     string      `json:"outputFile"`
+	OutputPosition int64       `json:"outputPosition"`
+	Writer         writerState `json:"writer"`
+
+	MessageOffset int32     `json:"messageOffset"`
+	RecordOffset  int32     `json:"recordOffset"`
+	MessagesDone  bool      `json:"messagesDone"`
+	Complete      bool      `json:"complete"`
+	Updated       time.Time `json:"updated"`
+}
+
+type checkpointCookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func validateCheckpoint() {
+	if len(CheckpointOpt) == 0 {
+		return
+	}
+	if len(OutputFileOpt) == 0 {
+		cobra.CheckErr(fmt.Errorf("checkpoint requires output-file"))
+	}
+	if OutputOpt == "table" {
+		cobra.CheckErr(fmt.Errorf("table output can't be resumed; use another output format with checkpoint"))
+	}
+	if StreamOpt {
+		cobra.CheckErr(fmt.Errorf("checkpoint and stream can't be combined"))
+	}
+}
+
+// loadCheckpoint reads the checkpoint at path. It returns nil when there is
+// none yet.
+func loadCheckpoint(path string) (*checkpoint, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(content, &cp); err != nil {
+		return nil, fmt.Errorf("checkpoint %s: %w", path, err)
+	}
+	if cp.Version != checkpointVersion {
+		return nil, fmt.Errorf("checkpoint %s: unsupported version %d", path, cp.Version)
+	}
+	return &cp, nil
+}
+
+// save atomically replaces the checkpoint file, so that a crash never leaves
+// a partial checkpoint behind.
+func (cp *checkpoint) save(path string) error {
+	cp.Updated = time.Now().UTC()
+	content, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, ".checkpoint-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(content, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// executeCheckpointedResults exports all results of a search job to
+// --output-file, committing the progress to --checkpoint after every page.
+// When the checkpoint exists, the export resumes where it stopped: the
+// output file is truncated to the last committed page and appended to. The
+// job is kept alive by executeJobResults.
+func executeCheckpointedResults(cmd *cobra.Command, jobId string) error {
+	if VerboseOpt {
+		fmt.Fprintf(os.Stderr, "%d\tSTART\tjobResultsGet::executeCheckpointedResults()\n", time.Now().UnixNano())
+	}
+	if all, _ := cmd.Flags().GetBool("all"); !all {
+		return fmt.Errorf("checkpoint requires --all")
+	}
+	messagesOnly, _ := cmd.Flags().GetBool("messages")
+	recordsOnly, _ := cmd.Flags().GetBool("records")
+
+	cp, err := loadCheckpoint(CheckpointOpt)
+	if err != nil {
+		return err
+	}
+	resumed := cp != nil
+	if resumed {
+		switch {
+		case cp.JobID != jobId:
+			return fmt.Errorf("checkpoint %s is for search job %s", CheckpointOpt, cp.JobID)
+		case cp.Endpoint != getClient().Endpoint():
+			// Job IDs are only unique within a deployment.
+			return fmt.Errorf("checkpoint %s is for search job %s at %s, not %s", CheckpointOpt, cp.JobID, cp.Endpoint, getClient().Endpoint())
+		case cp.OutputFile != OutputFileOpt || cp.Format != OutputOpt:
+			return fmt.Errorf("checkpoint %s is for %s output to %s", CheckpointOpt, cp.Format, cp.OutputFile)
+		case cp.Complete:
+			if !QuietOpt {
+				fmt.Fprintf(os.Stderr, "Export to %s is already complete\n", cp.OutputFile)
+			}
+			return nil
+		}
+		cookies := make([]*http.Cookie, 0, len(cp.Cookies))
+		for _, c := range cp.Cookies {
+			cookies = append(cookies, &http.Cookie{Name: c.Name, Value: c.Value, Path: "/"})
+		}
+		getClient().SetJobCookies(jobId, cookies)
+		if !QuietOpt {
+			fmt.Fprintf(os.Stderr, "Resuming export to %s at message %d, record %d\n", cp.OutputFile, cp.MessageOffset, cp.RecordOffset)
+		}
+	} else {
+		cp = &checkpoint{
+			Version:       checkpointVersion,
+			JobID:         jobId,
+			Endpoint:      getClient().Endpoint(),
+			Format:        OutputOpt,
+			OutputFile:    OutputFileOpt,
+			MessageOffset: OffsetOpt,
+			RecordOffset:  OffsetOpt,
+		}
+	}
+
+	status, err := pollStatus(cmd, jobId, true)
+	if resumed && client.IsNotFound(err) {
+		return fmt.Errorf("search job %s has expired, so the export can't be resumed: %w", jobId, err)
+	}
+	if err != nil {
+		return err
+	}
+	cp.Cookies = nil
+	for _, c := range getClient().JobCookies(jobId) {
+		cp.Cookies = append(cp.Cookies, checkpointCookie{Name: c.Name, Value: c.Value})
+	}
+
+	file, err := os.OpenFile(OutputFileOpt, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	// Rows written after the last commit are dropped and fetched again.
+	if err := file.Truncate(cp.OutputPosition); err != nil {
+		return err
+	}
+	if _, err := file.Seek(cp.OutputPosition, io.SeekStart); err != nil {
+		return err
+	}
+	writer, err := newResultWriter(file, OutputOpt)
+	if err != nil {
+		return err
+	}
+	if !messagesOnly && !recordsOnly {
+		writer.keyed("messages", "records")
+	}
+	writer.restore(cp.Writer)
+	commit := func() error {
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+		if err := file.Sync(); err != nil {
+			return err
+		}
+		position, err := file.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		cp.OutputPosition = position
+		cp.Writer = writer.state()
+		return cp.save(CheckpointOpt)
+	}
+	if err := commit(); err != nil {
+		return err
+	}
+
+	ctx := cmd.Context()
+	if !recordsOnly && !cp.MessagesDone {
+		_, err := fetchPages(ctx, messagePages(jobId), cp.MessageOffset, status.GetMessageCount(), true, func(fields []client.Field, rows []client.Row) error {
+			if err := writer.WritePage(fields, rows); err != nil {
+				return err
+			}
+			cp.MessageOffset += int32(len(rows))
+			return commit()
+		})
+		if err != nil {
+			return err
+		}
+		if err := writer.EndSection(); err != nil {
+			return err
+		}
+		cp.MessagesDone = true
+		if err := commit(); err != nil {
+			return err
+		}
+	}
+	if !messagesOnly {
+		_, err := fetchPages(ctx, recordPages(jobId), cp.RecordOffset, status.GetRecordCount(), true, func(fields []client.Field, rows []client.Row) error {
+			if err := writer.WritePage(fields, rows); err != nil {
+				return err
+			}
+			cp.RecordOffset += int32(len(rows))
+			return commit()
+		})
+		if err != nil {
+			return err
+		}
+		if err := writer.EndSection(); err != nil {
+			return err
+		}
+	}
+	cp.Complete = true
+	if err := commit(); err != nil {
+		return err
+	}
+	if !QuietOpt {
+		fmt.Fprintf(os.Stderr, "Exported %d messages and %d records to %s\n", cp.MessageOffset, cp.RecordOffset, cp.OutputFile)
+	}
+	if VerboseOpt {
+		fmt.Fprintf(os.Stderr, "%d\tEND\tjobResultsGet::executeCheckpointedResults()\n", time.Now().UnixNano())
+	}
+	return nil
+}
+
+func init() {
+	jobResultsGetCmd.Flags().StringVar(&CheckpointOpt, "checkpoint", "", "Record the export progress in this file after every page, and resume from it when it exists (requires --all and --output-file)")
+	jobResultsGetCmd.Flags().StringVar(&OutputFileOpt, "output-file", "", "Write the results to this file instead of stdout")
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nhoag/sumo-search-job-cli/fakeserver"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	scenario := messageScenario("", 250)
+	scenario.RecordFields = []fakeserver.Field{{Name: "_count"}, {Name: "host"}}
+	scenario.Records = []map[string]string{{"_count": "3", "host": "a"}, {"_count": "5", "host": "b"}}
+	api := newFakeAPI(t, fakeserver.Fixture{Scenarios: []fakeserver.Scenario{scenario}})
+	jobId := api.createJob(t, "error")
+	dir := t.TempDir()
+	export := func(output string, checkpoint string) (string, int) {
+		t.Helper()
+		_, stderr, code := runCLI(t, api.args("jobResultsGet", jobId, "-a", "-l", "100", "-O", "csv",
+			"--output-file", output, "--checkpoint", checkpoint, "--parallel", "1",
+			"--poll-interval", "1ms", "--retry-max-attempts", "1")...)
+		return stderr, code
+	}
+
+	// The third page of messages fails, after two pages were committed.
+	// Pages are fetched one at a time so that the failing page is known.
+	api.AddFault(fakeserver.Fault{Op: "messages", Status: 500, After: 2, Count: 1})
+	output := filepath.Join(dir, "resumed.csv")
+	checkpointFile := filepath.Join(dir, "resumed.json")
+	stderr, code := export(output, checkpointFile)
+	if code != 1 || !strings.Contains(stderr, "HTTP 500") {
+		t.Fatalf("exit code %d, want 1 with HTTP 500: %s", code, stderr)
+	}
+	cp, err := loadCheckpoint(checkpointFile)
+	if err != nil || cp == nil {
+		t.Fatalf("loadCheckpoint() = %v, %v", cp, err)
+	}
+	if cp.MessageOffset != 200 || cp.MessagesDone || cp.Complete {
+		t.Errorf("checkpoint at message %d (done %t, complete %t), want message 200", cp.MessageOffset, cp.MessagesDone, cp.Complete)
+	}
+	content, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(content)) != cp.OutputPosition {
+		t.Errorf("output is %d bytes, checkpoint is at %d", len(content), cp.OutputPosition)
+	}
+	// Simulate a crash between writing a page and committing it.
+	file, err := os.OpenFile(output, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(file, "200,m200\n201,m2")
+	file.Close()
+
+	stderr, code = export(output, checkpointFile)
+	if code != 0 {
+		t.Fatalf("resume exit code %d: %s", code, stderr)
+	}
+	if want := "Resuming export to " + output + " at message 200, record 0"; !strings.Contains(stderr, want) {
+		t.Errorf("stderr %q doesn't contain %q", stderr, want)
+	}
+	resumed, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An export that never failed writes the same file.
+	clean := filepath.Join(dir, "clean.csv")
+	if stderr, code := export(clean, filepath.Join(dir, "clean.json")); code != 0 {
+		t.Fatalf("clean export exit code %d: %s", code, stderr)
+	}
+	want, err := os.ReadFile(clean)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resumed) != string(want) {
+		t.Errorf("resumed export\n%s\nwant\n%s", resumed, want)
+	}
+	lines := strings.Split(strings.TrimSpace(string(resumed)), "\n")
+	seen := make(map[string]int)
+	for _, line := range lines {
+		seen[line]++
+	}
+	for i := 0; i < 250; i++ {
+		if row := fmt.Sprintf("%d,m%d", i, i); seen[row] != 1 {
+			t.Errorf("message %q written %d times", row, seen[row])
+		}
+	}
+	for _, row := range []string{"3,a", "5,b"} {
+		if seen[row] != 1 {
+			t.Errorf("record %q written %d times", row, seen[row])
+		}
+	}
+}
+
+func TestCheckpointMismatch(t *testing.T) {
+	api := newFakeAPI(t, fakeserver.Fixture{Scenarios: []fakeserver.Scenario{messageScenario("", 10)}})
+	jobId := api.createJob(t, "error")
+	dir := t.TempDir()
+	checkpointFile := filepath.Join(dir, "export.json")
+	export := func(args ...string) (string, int) {
+		t.Helper()
+		args = append([]string{"jobResultsGet", jobId, "-a", "-m", "--checkpoint", checkpointFile, "--poll-interval", "1ms"}, args...)
+		_, stderr, code := runCLI(t, api.args(args...)...)
+		return stderr, code
+	}
+	if stderr, code := export("-O", "csv", "--output-file", filepath.Join(dir, "export.csv")); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		stderr string
+	}{
+		{"complete", []string{"-O", "csv", "--output-file", filepath.Join(dir, "export.csv")}, 0, "is already complete"},
+		// A finished checkpoint must not hide that it is for another
+		// export.
+		{"other output file", []string{"-O", "csv", "--output-file", filepath.Join(dir, "other.csv")}, 1, "is for csv output to " + filepath.Join(dir, "export.csv")},
+		{"other format", []string{"-O", "ndjson", "--output-file", filepath.Join(dir, "export.csv")}, 1, "is for csv output to"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stderr, code := export(tt.args...)
+			if code != tt.code || !strings.Contains(stderr, tt.stderr) {
+				t.Errorf("exit code %d with %q, want %d with %q", code, stderr, tt.code, tt.stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/nhoag/sumo-search-job-cli/client"
+	"github.com/nhoag/sumo-search-job-cli/registry"
+)
+
+const (
+	// ExitInterrupted is the exit code after Ctrl-C or SIGTERM.
+	ExitInterrupted = 130
+	// cleanupTimeout bounds how long deleting jobs may take on the way out.
+	cleanupTimeout = 30 * time.Second
+)
+
+var (
+	KeepOnInterruptOpt bool
+
+	// interrupted is closed when a termination signal is received.
+	interrupted = make(chan struct{})
+
+	// liveJobs holds the search jobs created by this process that have not
+	// been deleted yet.
+	liveJobs   = make(map[string]bool)
+	liveJobsMu sync.Mutex
+)
+
+func trackJob(jobId string) {
+	liveJobsMu.Lock()
+	defer liveJobsMu.Unlock()
+	liveJobs[jobId] = true
+}
+
+func untrackJob(jobId string) {
+	liveJobsMu.Lock()
+	defer liveJobsMu.Unlock()
+	delete(liveJobs, jobId)
+}
+
+// registerJob records a created search job in the local job registry. The
+// registry is a convenience, so failures only produce a warning.
+func registerJob(location *url.URL, jobId string, jobDef JobDefinition) {
+	jobs := getRegistry()
+	if jobs == nil {
+		return
+	}
+	endpoint := getClient().Endpoint()
+	job := registry.Job{
+		ID:         jobId,
+		Query:      jobDef.Query,
+		From:       jobDef.From,
+		To:         jobDef.To,
+		TimeZone:   jobDef.Timezone,
+		Endpoint:   endpoint,
+		Deployment: client.EndpointDeployment(endpoint),
+		Profile:    viper.GetString("profile"),
+		Created:    time.Now().UTC(),
+	}
+	if location != nil {
+		job.Location = location.String()
+	}
+	if err := jobs.Add(job); err != nil && !QuietOpt {
+		fmt.Fprintf(os.Stderr, "Unable to record search job %s: %v\n", jobId, err)
+	}
+}
+
+// unregisterJobs removes search jobs from the local job registry.
+func unregisterJobs(jobIds ...string) {
+	jobs := getRegistry()
+	if jobs == nil {
+		return
+	}
+	if err := jobs.Remove(jobIds...); err != nil && !QuietOpt {
+		fmt.Fprintf(os.Stderr, "Unable to update job registry: %v\n", err)
+	}
+}
+
+// isInterrupted reports whether a termination signal has been received.
+func isInterrupted() bool {
+	select {
+	case <-interrupted:
+		return true
+	default:
+		return false
+	}
+}
+
+// deleteJob deletes a search job and stops tracking it.
+func deleteJob(ctx context.Context, jobId string) error {
+	err := getClient().DeleteSearchJob(ctx, jobId)
+	if err == nil || client.IsNotFound(err) {
+		untrackJob(jobId)
+		unregisterJobs(jobId)
+	}
+	return err
+}
+
+// cleanupJobs deletes every search job this process created and still holds.
+// It runs with its own context because the command context is usually
+// already cancelled at this point.
+func cleanupJobs() {
+	liveJobsMu.Lock()
+	jobIds := make([]string, 0, len(liveJobs))
+	for jobId := range liveJobs {
+		jobIds = append(jobIds, jobId)
+	}
+	liveJobsMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+	for _, jobId := range jobIds {
+		if err := deleteJob(ctx, jobId); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to delete search job %s: %v\n", jobId, err)
+			continue
+		}
+		if !QuietOpt {
+			fmt.Fprintf(os.Stderr, "Deleted search job %s\n", jobId)
+		}
+	}
+}
+
+// exitOnError cleans up and exits when err is not nil. Jobs created by this
+// process are deleted, unless the run was interrupted and
+// --keep-on-interrupt was given. Interrupted runs exit with ExitInterrupted,
+// and errors with an ExitCode method with that code.
+func exitOnError(err error) {
+	if err == nil {
+		return
+	}
+	if isInterrupted() {
+		if !KeepOnInterruptOpt {
+			cleanupJobs()
+		}
+		fmt.Fprintln(os.Stderr, "Interrupted")
+		os.Exit(ExitInterrupted)
+	}
+	cleanupJobs()
+	fmt.Fprintln(os.Stderr, "Error:", err)
+	code := 1
+	var exitCoder interface{ ExitCode() int }
+	if errors.As(err, &exitCoder) {
+		code = exitCoder.ExitCode()
+	}
+	os.Exit(code)
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.yaml.in/yaml/v3"
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the CLI configuration",
+	Long: `The config command inspects and updates the configuration file
+	(default $HOME/.sumo-search-job-cli.yaml).`,
+	// The active profile is not applied, so that a broken profile can still
+	// be inspected and replaced.
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+}
+
+// configProfilesCmd represents the config profiles command
+var configProfilesCmd = &cobra.Command{
+	Use:   "profiles",
+	Short: "Manage named configuration profiles",
+	Long: `Profiles are entries of the profiles section of the configuration
+	file. Each one may set its own accessId, accessKey, deployment, endpoint,
+	retry and rate_limit settings as well as timezone, limit and auto_parse
+	defaults, overriding the top-level settings. Select a profile with
+	--profile, the SUMO_PROFILE environment variable, or
+	'config profiles use'.`,
+}
+
+var configProfilesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the configured profiles",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		QuietOpt, _ = cmd.Flags().GetBool("quiet")
+		VerboseOpt, _ = cmd.Flags().GetBool("verbose")
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tSTART\tconfig profiles list\n", time.Now().UnixNano())
+		}
+		exitOnError(executeProfilesList(cmd, args))
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tEND\tconfig profiles list\n", time.Now().UnixNano())
+		}
+	},
+}
+
+var configProfilesShowCmd = &cobra.Command{
+	Use:   "show [PROFILE]",
+	Short: "Show the settings of a profile (default is the active profile)",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		QuietOpt, _ = cmd.Flags().GetBool("quiet")
+		VerboseOpt, _ = cmd.Flags().GetBool("verbose")
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tSTART\tconfig profiles show\n", time.Now().UnixNano())
+		}
+		exitOnError(executeProfilesShow(cmd, args))
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tEND\tconfig profiles show\n", time.Now().UnixNano())
+		}
+	},
+}
+
+var configProfilesUseCmd = &cobra.Command{
+	Use:   "use PROFILE",
+	Short: "Make a profile the default by saving it in the config file",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		QuietOpt, _ = cmd.Flags().GetBool("quiet")
+		VerboseOpt, _ = cmd.Flags().GetBool("verbose")
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tSTART\tconfig profiles use\n", time.Now().UnixNano())
+		}
+		exitOnError(executeProfilesUse(cmd, args))
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tEND\tconfig profiles use\n", time.Now().UnixNano())
+		}
+	},
+}
+
+func executeProfilesList(cmd *cobra.Command, args []string) error {
+	names := profileNames()
+	if len(names) == 0 {
+		if !QuietOpt {
+			fmt.Fprintf(os.Stderr, "No profiles configured\n")
+		}
+		return nil
+	}
+	active := strings.ToLower(viper.GetString("profile"))
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "\tPROFILE\tDEPLOYMENT\tENDPOINT")
+	for _, name := range names {
+		settings, _ := profileSettings(name)
+		marker := ""
+		if name == active {
+			marker = "*"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%v\t%v\n", marker, name, settingOrEmpty(settings, "deployment"), settingOrEmpty(settings, "endpoint"))
+	}
+	return table.Flush()
+}
+
+func executeProfilesShow(cmd *cobra.Command, args []string) error {
+	name := viper.GetString("profile")
+	if len(args) > 0 {
+		name = args[0]
+	}
+	if len(name) == 0 {
+		return fmt.Errorf("no active profile; pass a profile name")
+	}
+	settings, err := profileSettings(name)
+	if err != nil {
+		return err
+	}
+	if key, ok := settings["accesskey"].(string); ok {
+		settings["accesskey"] = maskSecret(key)
+	}
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(map[string]interface{}{strings.ToLower(name): settings}); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func executeProfilesUse(cmd *cobra.Command, args []string) error {
+	name := strings.ToLower(args[0])
+	if _, err := profileSettings(name); err != nil {
+		return err
+	}
+	path := viper.ConfigFileUsed()
+	if len(path) == 0 {
+		return fmt.Errorf("no config file found")
+	}
+	if err := setConfigValue(path, []string{"profile"}, name); err != nil {
+		return err
+	}
+	if !QuietOpt {
+		fmt.Fprintf(os.Stderr, "Using profile %s\n", name)
+	}
+	return nil
+}
+
+// setConfigValue sets a key of a YAML config file, given as the path of
+// mapping keys leading to it, keeping the rest of the file, including
+// comments, intact. Missing mappings along the path are created.
+func setConfigValue(path string, keys []string, value string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	mapping := doc.Content[0]
+	for i, key := range keys {
+		if mapping.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s: expected a mapping at %s", path, strings.Join(keys[:i], "."))
+		}
+		var child *yaml.Node
+		for j := 0; j+1 < len(mapping.Content); j += 2 {
+			if strings.EqualFold(mapping.Content[j].Value, key) {
+				child = mapping.Content[j+1]
+				if i == len(keys)-1 {
+					mapping.Content[j+1] = &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+				}
+			}
+		}
+		if child == nil {
+			child = &yaml.Node{Kind: yaml.MappingNode}
+			if i == len(keys)-1 {
+				child = &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+			}
+			mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, child)
+		}
+		mapping = child
+	}
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, out.Bytes(), info.Mode().Perm())
+}
+
+func settingOrEmpty(settings map[string]interface{}, key string) interface{} {
+	if value, ok := settings[key]; ok {
+		return value
+	}
+	return ""
+}
+
+// maskSecret hides all but the last four characters of a secret.
+func maskSecret(secret string) string {
+	if len(secret) <= 4 {
+		return strings.Repeat("*", len(secret))
+	}
+	return strings.Repeat("*", len(secret)-4) + secret[len(secret)-4:]
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configProfilesCmd)
+	configProfilesCmd.AddCommand(configProfilesListCmd)
+	configProfilesCmd.AddCommand(configProfilesShowCmd)
+	configProfilesCmd.AddCommand(configProfilesUseCmd)
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nhoag/sumo-search-job-cli/fakeserver"
+)
+
+var (
+	ListenOpt  string
+	FixtureOpt string
+	FaultOpts  []string
+)
+
+// fakeServerCmd represents the fakeServer command
+var fakeServerCmd = &cobra.Command{
+	Use:   "fakeServer",
+	Short: "Run a local fake Sumo Logic Search Job API",
+	Long: `The fakeServer command serves an in-memory implementation of the
+	Search Job API for offline development and tests. Search job results and
+	state transitions are scripted with a fixture file, and errors such as 429
+	or 500 can be injected. Point the CLI at it with --endpoint or the
+	endpoint config option.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		QuietOpt, _ = cmd.Flags().GetBool("quiet")
+		VerboseOpt, _ = cmd.Flags().GetBool("verbose")
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tSTART\tfakeServer\n", time.Now().UnixNano())
+		}
+		exitOnError(executeFakeServer(cmd, args))
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tEND\tfakeServer\n", time.Now().UnixNano())
+		}
+	},
+}
+
+func executeFakeServer(cmd *cobra.Command, args []string) error {
+	if VerboseOpt {
+		fmt.Fprintf(os.Stderr, "%d\tSTART\tfakeServer::executeFakeServer()\n", time.Now().UnixNano())
+	}
+	var config fakeserver.Config
+	if len(FixtureOpt) > 0 {
+		fixture, err := fakeserver.LoadFixture(FixtureOpt)
+		if err != nil {
+			return err
+		}
+		config.Fixture = fixture
+	}
+	config.AccessID, _ = cmd.Flags().GetString("access-id")
+	config.AccessKey, _ = cmd.Flags().GetString("access-key")
+	server := fakeserver.New(config)
+	for _, spec := range FaultOpts {
+		fault, err := fakeserver.ParseFault(spec)
+		if err != nil {
+			return err
+		}
+		server.AddFault(fault)
+	}
+
+	listener, err := net.Listen("tcp", ListenOpt)
+	if err != nil {
+		return err
+	}
+	if !QuietOpt {
+		fmt.Fprintf(os.Stderr, "Endpoint:\thttp://%s/api\n", listener.Addr())
+	}
+
+	httpServer := &http.Server{Handler: server}
+	go func() {
+		<-cmd.Context().Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+	if err := httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	if VerboseOpt {
+		fmt.Fprintf(os.Stderr, "%d\tEND\tfakeServer::executeFakeServer()\n", time.Now().UnixNano())
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(fakeServerCmd)
+	fakeServerCmd.Flags().StringVarP(&ListenOpt, "listen", "L", "127.0.0.1:8080", "Address to listen on")
+	fakeServerCmd.Flags().StringVarP(&FixtureOpt, "fixture", "F", "", "Path to fixture file with scenarios and faults")
+	fakeServerCmd.Flags().StringArrayVar(&FaultOpts, "fault", nil, "Inject errors as OP:STATUS[:COUNT] (OP is create, status, messages, records, delete or *)")
+	fakeServerCmd.Flags().String("access-id", "", "Require this access ID")
+	fakeServerCmd.Flags().String("access-key", "", "Require this access key")
+}
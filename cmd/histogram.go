@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/nhoag/sumo-search-job-cli/client"
+)
+
+var HistogramOutputOpt string
+
+// histogramFields are the columns printed by jobStatusCheck --histogram.
+var histogramFields = []client.Field{
+	{Name: "startTimestamp", FieldType: "long"}, {Name: "start"},
+	{Name: "length", FieldType: "long"}, {Name: "count", FieldType: "long"},
+}
+
+// sparkBlocks are the sparkline levels, from no messages to the peak.
+var sparkBlocks = []rune(" ▁▂▃▄▅▆▇█")
+
+// defaultSparklineWidth is used when stderr is not a terminal.
+const defaultSparklineWidth = 80
+
+// mergeHistogram adds polled buckets to histogram, keyed by start time. A
+// later poll replaces the count of a bucket seen before.
+func mergeHistogram(histogram map[int64]client.HistogramBucket, buckets []client.HistogramBucket) {
+	for _, bucket := range buckets {
+		histogram[bucket.StartTimestamp] = bucket
+	}
+}
+
+// sortedHistogram returns the buckets in time order.
+func sortedHistogram(histogram map[int64]client.HistogramBucket) []client.HistogramBucket {
+	buckets := make([]client.HistogramBucket, 0, len(histogram))
+	for _, bucket := range histogram {
+		buckets = append(buckets, bucket)
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].StartTimestamp < buckets[j].StartTimestamp
+	})
+	return buckets
+}
+
+// printHistogram writes the buckets to stdout in --output format, and a
+// sparkline of them to stderr.
+func printHistogram(buckets []client.HistogramBucket) error {
+	writer, err := newResultWriter(os.Stdout, HistogramOutputOpt)
+	if err != nil {
+		return err
+	}
+	rows := make([]client.Row, 0, len(buckets))
+	for _, bucket := range buckets {
+		rows = append(rows, client.Row{
+			"startTimestamp": strconv.FormatInt(bucket.StartTimestamp, 10),
+			"start":          time.UnixMilli(bucket.StartTimestamp).Local().Format(time.RFC3339),
+			"length":         strconv.FormatInt(bucket.Length, 10),
+			"count":          strconv.FormatInt(bucket.Count, 10),
+		})
+	}
+	if err := writer.WritePage(histogramFields, rows); err != nil {
+		return err
+	}
+	if err := writer.EndSection(); err != nil {
+		return err
+	}
+	if !QuietOpt {
+		width := defaultSparklineWidth
+		if w, _, err := term.GetSize(int(os.Stderr.Fd())); err == nil && w > 0 {
+			width = w
+		}
+		printSparkline(os.Stderr, buckets, width)
+	}
+	return nil
+}
+
+// printSparkline draws the message volume over the search window on one
+// line of at most width characters, followed by the window bounds.
+func printSparkline(out io.Writer, buckets []client.HistogramBucket, width int) {
+	if len(buckets) == 0 {
+		fmt.Fprintf(out, "No histogram buckets\n")
+		return
+	}
+	start := buckets[0].StartTimestamp
+	end := start
+	minLength := int64(math.MaxInt64)
+	var total int64
+	for _, bucket := range buckets {
+		end = max(end, bucket.StartTimestamp+bucket.Length)
+		if bucket.Length > 0 {
+			minLength = min(minLength, bucket.Length)
+		}
+		total += bucket.Count
+	}
+
+	// Each column covers a whole number of buckets, so that uniform buckets
+	// neither leave gaps nor alias.
+	columns := int64(max(width, 10))
+	span := max(end-start, 1)
+	step := max((span+columns-1)/columns, 1)
+	if minLength != math.MaxInt64 {
+		step = (step + minLength - 1) / minLength * minLength
+	}
+	counts := make([]int64, (span+step-1)/step)
+	for _, bucket := range buckets {
+		// A zero-length bucket may start at the end of the window.
+		column := min((bucket.StartTimestamp-start)/step, int64(len(counts)-1))
+		counts[column] += bucket.Count
+	}
+	var peak int64
+	for _, count := range counts {
+		peak = max(peak, count)
+	}
+
+	var line strings.Builder
+	for _, count := range counts {
+		level := 0
+		if count > 0 {
+			level = int(math.Ceil(float64(count) / float64(peak) * float64(len(sparkBlocks)-1)))
+		}
+		line.WriteRune(sparkBlocks[level])
+	}
+	fmt.Fprintln(out, line.String())
+	fmt.Fprintf(out, "%s - %s: %d messages, peak %d per %s\n",
+		time.UnixMilli(start).Local().Format(time.RFC3339),
+		time.UnixMilli(end).Local().Format(time.RFC3339),
+		total, peak, time.Duration(step)*time.Millisecond)
+}
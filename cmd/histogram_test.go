@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/nhoag/sumo-search-job-cli/client"
+)
+
+func TestPrintSparklineTrailingEmptyBucket(t *testing.T) {
+	buckets := []client.HistogramBucket{
+		{StartTimestamp: 0, Length: 1000, Count: 4},
+		{StartTimestamp: 1000, Length: 1000, Count: 2},
+		{StartTimestamp: 2000, Length: 0, Count: 1},
+	}
+	var out bytes.Buffer
+	printSparkline(&out, buckets, 10)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %q, want a sparkline and a summary", out.String())
+	}
+	if got := len([]rune(lines[0])); got != 2 {
+		t.Errorf("got %d columns, want 2", got)
+	}
+	if !strings.Contains(lines[1], ": 7 messages, peak 4 per 1s") {
+		t.Errorf("got summary %q", lines[1])
+	}
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nhoag/sumo-search-job-cli/fakeserver"
+)
+
+func TestJobCreate(t *testing.T) {
+	api := newFakeAPI(t, fakeserver.Fixture{})
+	// The endpoint comes from the config file, as it would for scripts.
+	home := t.TempDir()
+	config := "endpoint: " + api.endpoint + "\nrate_limit:\n  rate: 0\n"
+	if err := os.WriteFile(filepath.Join(home, ".sumo-search-job-cli.yaml"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	run := startCLI(t, home, "jobCreate", "-q", "error", "-f", "2024-01-01T00:00:00", "-t", "2024-01-01T01:00:00", "-z", "Europe/Paris")
+	if code := run.wait(t); code != 0 {
+		t.Fatalf("exit code %d: %s", code, run.stderr.String())
+	}
+	if !strings.Contains(run.stderr.String(), "Job ID:\t\tFAKE000000000001") {
+		t.Errorf("stderr %q doesn't report the job ID", run.stderr.String())
+	}
+	definition, ok := api.Definition("FAKE000000000001")
+	if !ok {
+		t.Fatal("no search job was created")
+	}
+	want := map[string]interface{}{"query": "error", "from": "2024-01-01T00:00:00", "to": "2024-01-01T01:00:00", "timeZone": "Europe/Paris"}
+	for key, value := range want {
+		if definition[key] != value {
+			t.Errorf("%s = %v, want %v", key, definition[key], value)
+		}
+	}
+}
+
+func TestJobCreateFaults(t *testing.T) {
+	tests := []struct {
+		name   string
+		fault  fakeserver.Fault
+		code   int
+		stderr string
+	}{
+		{"rate limited once", fakeserver.Fault{Op: "create", Status: 429, Count: 1}, 0, "Job ID:"},
+		{"server error", fakeserver.Fault{Op: "create", Status: 500, Count: 1}, 1, "Error: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakeAPI(t, fakeserver.Fixture{Faults: []fakeserver.Fault{tt.fault}})
+			_, stderr, code := runCLI(t, api.args("jobCreate", "-q", "error", "-d", "1h")...)
+			if code != tt.code {
+				t.Errorf("exit code %d, want %d", code, tt.code)
+			}
+			if !strings.Contains(stderr, tt.stderr) {
+				t.Errorf("stderr %q doesn't contain %q", stderr, tt.stderr)
+			}
+		})
+	}
+}
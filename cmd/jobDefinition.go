@@ -0,0 +1,475 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/nhoag/sumo-search-job-cli/client"
+	"github.com/nhoag/sumo-search-job-cli/queries"
+	"github.com/nhoag/sumo-search-job-cli/timeexpr"
+)
+
+// AutoParsingModes lists the values accepted for autoParsingMode.
+var AutoParsingModes = []string{"performance", "intelligent"}
+
+var PrintJobOpt bool
+
+// savedQuery is the saved query run by 'query run', whose fields rank just
+// above the SUMO_* environment variables.
+var savedQuery *queries.Query
+
+// jobEnv maps job definition fields to the environment variables that set
+// them, over the config file and under everything given on the command line.
+var jobEnv = []struct {
+	field string
+	env   string
+}{
+	{"query", "SUMO_QUERY"},
+	{"from", "SUMO_FROM"},
+	{"to", "SUMO_TO"},
+	{"duration", "SUMO_DURATION"},
+	{"timeZone", "SUMO_TIMEZONE"},
+	{"byReceiptTime", "SUMO_BY_RECEIPT_TIME"},
+	{"autoParsingMode", "SUMO_AUTO_PARSE"},
+}
+
+// jobLayer is a partial job definition from one source. Nil fields are not
+// set by the source.
+type jobLayer struct {
+	Query           *string `json:"query"`
+	From            *string `json:"from"`
+	To              *string `json:"to"`
+	Timezone        *string `json:"timeZone"`
+	ByReceiptTime   *bool   `json:"byReceiptTime"`
+	AutoParsingMode *string `json:"autoParsingMode"`
+	// Duration is only set by flags and the environment.
+	Duration *string `json:"-"`
+}
+
+// sourcedLayer is a job layer and where it came from. Layers made of
+// several flags or variables name the source of each field in fields.
+type sourcedLayer struct {
+	source string
+	fields map[string]string
+	layer  jobLayer
+}
+
+func (l sourcedLayer) sourceOf(field string) string {
+	if source, ok := l.fields[field]; ok {
+		return source
+	}
+	return l.source
+}
+
+// jobSources records where each field of a merged job definition came from.
+type jobSources map[string]string
+
+// decodeJobLayer decodes a JSON job definition. Unknown keys are rejected so
+// that typos don't silently change the search.
+func decodeJobLayer(content []byte) (jobLayer, error) {
+	var layer jobLayer
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&layer); err != nil {
+		return jobLayer{}, err
+	}
+	if decoder.More() {
+		return jobLayer{}, fmt.Errorf("unexpected data after the job definition")
+	}
+	return layer, nil
+}
+
+func validateAutoParsingMode(mode string) error {
+	if len(mode) == 0 {
+		return nil
+	}
+	for _, valid := range AutoParsingModes {
+		if mode == valid {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid auto-parsing mode %q (valid: %s)", mode, strings.Join(AutoParsingModes, ", "))
+}
+
+// searchJobDefinition translates the job definition into the API request.
+func (jobDef JobDefinition) searchJobDefinition() client.SearchJobDefinition {
+	return client.SearchJobDefinition{
+		Query:           jobDef.Query,
+		From:            jobDef.From,
+		To:              jobDef.To,
+		TimeZone:        jobDef.Timezone,
+		ByReceiptTime:   jobDef.ByReceiptTime,
+		AutoParsingMode: jobDef.AutoParsingMode,
+	}
+}
+
+// buildPayload assembles the search job definition and resolves its time
+// expressions into a concrete window.
+func buildPayload(cmd *cobra.Command, args []string) (JobDefinition, error) {
+	vars, err := templateVars()
+	if err != nil {
+		return JobDefinition{}, err
+	}
+	jobDef, _, err := resolveJobDefinition(cmd, vars)
+	return jobDef, err
+}
+
+// resolveJobDefinition merges the job definition field by field from, in
+// increasing order of precedence: defaults, the profile, SUMO_* environment
+// variables, the saved query, --job-file, --job and individual flags.
+// Template placeholders in the merged fields are then filled from vars. It returns the definition and
+// the source of each field.
+func resolveJobDefinition(cmd *cobra.Command, vars map[string]string) (JobDefinition, jobSources, error) {
+	if VerboseOpt {
+		fmt.Fprintf(os.Stderr, "%d\tSTART\tjobCreate::resolveJobDefinition()\n", time.Now().UnixNano())
+	}
+	envLayer, err := jobEnvLayer()
+	if err != nil {
+		return JobDefinition{}, nil, err
+	}
+	utc := "UTC"
+	layers := []sourcedLayer{
+		{source: "default", layer: jobLayer{Timezone: &utc}},
+		{source: profileSource(), layer: profileJobLayer()},
+		envLayer,
+	}
+	if savedQuery != nil {
+		layers = append(layers, sourcedLayer{source: "query " + savedQuery.Name, layer: savedQueryLayer(savedQuery)})
+	}
+	if len(JobFileOpt) > 0 {
+		content, err := os.ReadFile(JobFileOpt)
+		if err != nil {
+			return JobDefinition{}, nil, err
+		}
+		layer, err := decodeJobLayer(content)
+		if err != nil {
+			return JobDefinition{}, nil, fmt.Errorf("%s: %w", JobFileOpt, err)
+		}
+		layers = append(layers, sourcedLayer{source: "--job-file " + JobFileOpt, layer: layer})
+	}
+	if len(JobOpt) > 0 {
+		layer, err := decodeJobLayer([]byte(JobOpt))
+		if err != nil {
+			return JobDefinition{}, nil, fmt.Errorf("job: %w", err)
+		}
+		layers = append(layers, sourcedLayer{source: "--job", layer: layer})
+	}
+
+	flagLayer, err := jobFlagLayer(cmd)
+	if err != nil {
+		return JobDefinition{}, nil, err
+	}
+	layers = append(layers, flagLayer)
+
+	var merged jobLayer
+	sources := make(jobSources)
+	for _, l := range layers {
+		layer := l.layer
+		// A duration replaces the window of lower layers, except for the
+		// bounds given alongside it.
+		if layer.Duration != nil {
+			merged.Duration, sources["duration"] = layer.Duration, l.sourceOf("duration")
+			if layer.From == nil {
+				merged.From = nil
+				delete(sources, "from")
+			}
+			if layer.To == nil {
+				merged.To = nil
+				delete(sources, "to")
+			}
+		}
+		if layer.Query != nil {
+			merged.Query, sources["query"] = layer.Query, l.sourceOf("query")
+		}
+		if layer.From != nil {
+			merged.From, sources["from"] = layer.From, l.sourceOf("from")
+		}
+		if layer.To != nil {
+			merged.To, sources["to"] = layer.To, l.sourceOf("to")
+		}
+		if layer.Timezone != nil {
+			merged.Timezone, sources["timeZone"] = layer.Timezone, l.sourceOf("timeZone")
+		}
+		if layer.ByReceiptTime != nil {
+			merged.ByReceiptTime, sources["byReceiptTime"] = layer.ByReceiptTime, l.sourceOf("byReceiptTime")
+		}
+		if layer.AutoParsingMode != nil {
+			merged.AutoParsingMode, sources["autoParsingMode"] = layer.AutoParsingMode, l.sourceOf("autoParsingMode")
+		}
+	}
+
+	for field, value := range map[string]**string{
+		"query":           &merged.Query,
+		"from":            &merged.From,
+		"to":              &merged.To,
+		"duration":        &merged.Duration,
+		"timeZone":        &merged.Timezone,
+		"autoParsingMode": &merged.AutoParsingMode,
+	} {
+		if *value == nil {
+			continue
+		}
+		rendered, err := renderTemplate(field, **value, vars)
+		if err != nil {
+			return JobDefinition{}, nil, err
+		}
+		*value = &rendered
+	}
+
+	jobDef := JobDefinition{
+		Query:           deref(merged.Query),
+		Timezone:        deref(merged.Timezone),
+		AutoParsingMode: deref(merged.AutoParsingMode),
+	}
+	if merged.ByReceiptTime != nil {
+		jobDef.ByReceiptTime = *merged.ByReceiptTime
+	}
+	if len(strings.TrimSpace(jobDef.Query)) == 0 {
+		return JobDefinition{}, nil, fmt.Errorf("a query is required")
+	}
+	if err := validateAutoParsingMode(jobDef.AutoParsingMode); err != nil {
+		return JobDefinition{}, nil, err
+	}
+	location, err := time.LoadLocation(jobDef.Timezone)
+	if err != nil {
+		return JobDefinition{}, nil, fmt.Errorf("unknown timezone %q", jobDef.Timezone)
+	}
+	from, to, err := timeexpr.Window(deref(merged.From), deref(merged.To), deref(merged.Duration), time.Now(), location)
+	if err != nil {
+		return JobDefinition{}, nil, err
+	}
+	jobDef.From = from.Format(timeexpr.Layout)
+	jobDef.To = to.Format(timeexpr.Layout)
+	describeWindow(sources, merged, jobDef)
+	if VerboseOpt {
+		fmt.Fprintf(os.Stderr, "%d\tEND\tjobCreate::resolveJobDefinition()\n", time.Now().UnixNano())
+	}
+	return jobDef, sources, nil
+}
+
+// addJobDefinitionFlags defines the flags that make up a job definition, of
+// the commands that create search jobs. jobFlagLayer reads them.
+func addJobDefinitionFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&JobOpt, "job", "j", "", "Search job definition")
+	flags.StringVarP(&JobFileOpt, "job-file", "J", "", "Path to file with full search job definition")
+
+	flags.StringVarP(&QueryOpt, "query", "q", "", "Search query")
+	flags.StringVarP(&QueryFileOpt, "query-file", "Q", "", "Path to file with search query")
+
+	flags.StringVarP(&DurationOpt, "duration", "d", "", "Size of time span, ending at --to or now, or starting at --from (e.g. 3h, 1d)")
+	flags.StringVarP(&FromTimeOpt, "from", "f", "", "Search window start time (e.g. 2017-07-16T00:00:00, RFC3339, epoch seconds or millis, -15m, now-1d@d, yesterday)")
+	flags.StringVarP(&ToTimeOpt, "to", "t", "", "Search window end time, default now (same forms as --from)")
+	flags.StringVarP(&TimeZoneOpt, "timezone", "z", "UTC", "Timezone to use for search window")
+	flags.BoolP("by-receipt-time", "b", false, "Use receipt-time instead of log message timestamps")
+	flags.StringVarP(&AutoParsingModeOpt, "auto-parse", "A", "", "Specify auto-parsing mode to use ('intelligent' automatically runs field extraction rules)")
+
+	flags.StringArrayVar(&VarOpts, "var", nil, "Set a template variable used in the query or job definition as KEY=VALUE (repeatable)")
+	flags.StringVar(&VarsFileOpt, "vars-file", "", "YAML or JSON file of template variables")
+}
+
+// jobFlagLayer returns the job fields given as individual flags.
+func jobFlagLayer(cmd *cobra.Command) (sourcedLayer, error) {
+	flags := cmd.Flags()
+	changed := func(name string) bool {
+		flag := flags.Lookup(name)
+		return flag != nil && flag.Changed
+	}
+	if changed("query") && changed("query-file") {
+		return sourcedLayer{}, fmt.Errorf("query and query-file can't both be given")
+	}
+	l := sourcedLayer{source: "flags", fields: make(map[string]string)}
+	for _, f := range []struct {
+		name  string
+		field string
+		value string
+		set   func(*jobLayer, *string)
+	}{
+		{"query", "query", QueryOpt, func(l *jobLayer, v *string) { l.Query = v }},
+		{"from", "from", FromTimeOpt, func(l *jobLayer, v *string) { l.From = v }},
+		{"to", "to", ToTimeOpt, func(l *jobLayer, v *string) { l.To = v }},
+		{"duration", "duration", DurationOpt, func(l *jobLayer, v *string) { l.Duration = v }},
+		{"timezone", "timeZone", TimeZoneOpt, func(l *jobLayer, v *string) { l.Timezone = v }},
+		{"auto-parse", "autoParsingMode", AutoParsingModeOpt, func(l *jobLayer, v *string) { l.AutoParsingMode = v }},
+	} {
+		if changed(f.name) {
+			value := f.value
+			f.set(&l.layer, &value)
+			l.fields[f.field] = "--" + f.name
+		}
+	}
+	if changed("query-file") {
+		content, err := os.ReadFile(QueryFileOpt)
+		if err != nil {
+			return sourcedLayer{}, err
+		}
+		query := string(content)
+		l.layer.Query = &query
+		l.fields["query"] = "--query-file " + QueryFileOpt
+	}
+	if changed("by-receipt-time") {
+		byReceiptTime, _ := flags.GetBool("by-receipt-time")
+		l.layer.ByReceiptTime = &byReceiptTime
+		l.fields["byReceiptTime"] = "--by-receipt-time"
+	}
+	return l, nil
+}
+
+// jobEnvLayer returns the job fields given as SUMO_* environment variables.
+func jobEnvLayer() (sourcedLayer, error) {
+	l := sourcedLayer{source: "environment", fields: make(map[string]string)}
+	for _, e := range jobEnv {
+		value, ok := os.LookupEnv(e.env)
+		if !ok || len(value) == 0 {
+			continue
+		}
+		switch e.field {
+		case "query":
+			l.layer.Query = &value
+		case "from":
+			l.layer.From = &value
+		case "to":
+			l.layer.To = &value
+		case "duration":
+			l.layer.Duration = &value
+		case "timeZone":
+			l.layer.Timezone = &value
+		case "autoParsingMode":
+			l.layer.AutoParsingMode = &value
+		case "byReceiptTime":
+			byReceiptTime, err := strconv.ParseBool(value)
+			if err != nil {
+				return sourcedLayer{}, fmt.Errorf("%s: invalid boolean %q", e.env, value)
+			}
+			l.layer.ByReceiptTime = &byReceiptTime
+		}
+		l.fields[e.field] = "$" + e.env
+	}
+	return l, nil
+}
+
+// profileJobLayer returns the search defaults of the config file and active
+// profile.
+func profileJobLayer() jobLayer {
+	var layer jobLayer
+	if viper.InConfig("timezone") {
+		timezone := viper.GetString("timezone")
+		layer.Timezone = &timezone
+	}
+	if viper.InConfig("auto_parse") {
+		mode := viper.GetString("auto_parse")
+		layer.AutoParsingMode = &mode
+	}
+	return layer
+}
+
+// savedQueryLayer returns the job fields set by a saved query.
+func savedQueryLayer(query *queries.Query) jobLayer {
+	optional := func(value string) *string {
+		if len(value) == 0 {
+			return nil
+		}
+		return &value
+	}
+	return jobLayer{
+		Query:           optional(query.Query),
+		From:            optional(query.From),
+		To:              optional(query.To),
+		Duration:        optional(query.Duration),
+		Timezone:        optional(query.TimeZone),
+		ByReceiptTime:   query.ByReceiptTime,
+		AutoParsingMode: optional(query.AutoParsingMode),
+	}
+}
+
+// profileSource names the config layer in --print-job output.
+func profileSource() string {
+	if profile := viper.GetString("profile"); len(profile) > 0 {
+		return "profile " + profile
+	}
+	return "config file"
+}
+
+// describeWindow annotates the sources of from and to with the expressions
+// they were resolved from.
+func describeWindow(sources jobSources, merged jobLayer, jobDef JobDefinition) {
+	annotate := func(field string, expr *string, value string) {
+		if expr == nil {
+			return
+		}
+		if *expr != value {
+			sources[field] += " (" + *expr + ")"
+		}
+	}
+	annotate("from", merged.From, jobDef.From)
+	annotate("to", merged.To, jobDef.To)
+	if merged.Duration != nil {
+		if merged.From == nil {
+			sources["from"] = sources["duration"] + " (" + *merged.Duration + " before to)"
+		} else if merged.To == nil {
+			sources["to"] = sources["duration"] + " (" + *merged.Duration + " after from)"
+		}
+	}
+	if _, ok := sources["to"]; !ok {
+		sources["to"] = "now"
+		if merged.From != nil && timeexpr.IsPreset(*merged.From) {
+			sources["to"] = "end of " + *merged.From
+		}
+	}
+	delete(sources, "duration")
+}
+
+// executePrintJob prints the effective job definition for --print-job.
+func executePrintJob(cmd *cobra.Command, args []string) error {
+	vars, err := templateVars()
+	if err != nil {
+		return err
+	}
+	jobDef, sources, err := resolveJobDefinition(cmd, vars)
+	if err != nil {
+		return err
+	}
+	return printJobDefinition(os.Stdout, jobDef, sources)
+}
+
+// printJobDefinition writes the effective definition and the source of each
+// field.
+func printJobDefinition(out io.Writer, jobDef JobDefinition, sources jobSources) error {
+	table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "FIELD\tVALUE\tSOURCE")
+	for _, field := range []struct {
+		name  string
+		value string
+	}{
+		{"query", strings.NewReplacer("\n", `\n`, "\t", `\t`).Replace(jobDef.Query)},
+		{"from", jobDef.From},
+		{"to", jobDef.To},
+		{"timeZone", jobDef.Timezone},
+		{"byReceiptTime", strconv.FormatBool(jobDef.ByReceiptTime)},
+		{"autoParsingMode", jobDef.AutoParsingMode},
+	} {
+		source, ok := sources[field.name]
+		if !ok {
+			source = "default"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\n", field.name, field.value, source)
+	}
+	return table.Flush()
+}
+
+func deref(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/nhoag/sumo-search-job-cli/queries"
+)
+
+// resolveArgs resolves the job definition given by args, as jobCreate would.
+func resolveArgs(t *testing.T, args ...string) (JobDefinition, jobSources) {
+	t.Helper()
+	cmd := &cobra.Command{Use: "test"}
+	addJobDefinitionFlags(cmd.Flags())
+	if err := cmd.Flags().Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	jobDef, sources, err := resolveJobDefinition(cmd, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jobDef, sources
+}
+
+// isolateJobSources clears the job definition sources outside of args: the
+// SUMO_* environment, the saved query and the config file. The flag values,
+// which are shared with the commands, are reset afterwards.
+func isolateJobSources(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() { addJobDefinitionFlags(pflag.NewFlagSet("reset", pflag.ContinueOnError)) })
+	for _, e := range jobEnv {
+		t.Setenv(e.env, "")
+	}
+	savedQuery = nil
+	t.Cleanup(func() { savedQuery = nil })
+	useConfig(t, "")
+}
+
+// useConfig loads content as the config file.
+func useConfig(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	viper.SetConfigFile(path)
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.WriteFile(path, nil, 0600)
+		viper.ReadInConfig()
+	})
+}
+
+func TestJobDefinitionSourcesProduceSameRequest(t *testing.T) {
+	const golden = `{"query":"error | count by _sourceHost","from":"2024-01-01T00:00:00","to":"2024-01-01T06:00:00","timeZone":"Europe/Berlin","byReceiptTime":true,"autoParsingMode":"intelligent"}`
+	definition := `{"query": "error | count by _sourceHost", "from": "2024-01-01T00:00:00", "to": "2024-01-01T06:00:00", "timeZone": "Europe/Berlin", "byReceiptTime": true, "autoParsingMode": "intelligent"}`
+	dir := t.TempDir()
+	jobFile := filepath.Join(dir, "job.json")
+	if err := os.WriteFile(jobFile, []byte(definition), 0600); err != nil {
+		t.Fatal(err)
+	}
+	queryFile := filepath.Join(dir, "query.txt")
+	if err := os.WriteFile(queryFile, []byte("error | count by _sourceHost"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	byReceiptTime := true
+
+	tests := []struct {
+		name  string
+		args  []string
+		env   map[string]string
+		query *queries.Query
+	}{
+		{
+			name: "flags",
+			args: []string{"-q", "error | count by _sourceHost", "-f", "2024-01-01T00:00:00", "-t", "2024-01-01T06:00:00", "-z", "Europe/Berlin", "-b", "-A", "intelligent"},
+		},
+		{
+			name: "query file and duration",
+			args: []string{"-Q", queryFile, "-d", "6h", "-t", "2024-01-01T06:00:00", "-z", "Europe/Berlin", "--by-receipt-time", "--auto-parse", "intelligent"},
+		},
+		{
+			name: "job",
+			args: []string{"--job", definition},
+		},
+		{
+			name: "job file",
+			args: []string{"--job-file", jobFile},
+		},
+		{
+			name: "environment",
+			env: map[string]string{
+				"SUMO_QUERY":           "error | count by _sourceHost",
+				"SUMO_FROM":            "2024-01-01T00:00:00",
+				"SUMO_TO":              "2024-01-01T06:00:00",
+				"SUMO_TIMEZONE":        "Europe/Berlin",
+				"SUMO_BY_RECEIPT_TIME": "true",
+				"SUMO_AUTO_PARSE":      "intelligent",
+			},
+		},
+		{
+			name: "saved query",
+			query: &queries.Query{
+				Name:            "hosts",
+				Query:           "error | count by _sourceHost",
+				From:            "2024-01-01T00:00:00",
+				To:              "2024-01-01T06:00:00",
+				TimeZone:        "Europe/Berlin",
+				ByReceiptTime:   &byReceiptTime,
+				AutoParsingMode: "intelligent",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateJobSources(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			savedQuery = tt.query
+			jobDef, _ := resolveArgs(t, tt.args...)
+			body, err := json.Marshal(jobDef.searchJobDefinition())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != golden {
+				t.Errorf("request body\n got: %s\nwant: %s", body, golden)
+			}
+		})
+	}
+}
+
+func TestJobDefinitionPrecedence(t *testing.T) {
+	jobFile := filepath.Join(t.TempDir(), "job.json")
+	if err := os.WriteFile(jobFile, []byte(`{"timeZone": "Europe/Paris"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each layer sets the time zone, over the layers before it.
+	layers := []struct {
+		timeZone string
+		source   string
+		apply    func(t *testing.T, args []string) []string
+	}{
+		{"UTC", "default", func(t *testing.T, args []string) []string { return args }},
+		{"America/New_York", "config file", func(t *testing.T, args []string) []string {
+			useConfig(t, "timezone: America/New_York\n")
+			return args
+		}},
+		{"America/Chicago", "$SUMO_TIMEZONE", func(t *testing.T, args []string) []string {
+			t.Setenv("SUMO_TIMEZONE", "America/Chicago")
+			return args
+		}},
+		{"Asia/Tokyo", "query saved", func(t *testing.T, args []string) []string {
+			savedQuery = &queries.Query{Name: "saved", TimeZone: "Asia/Tokyo"}
+			return args
+		}},
+		{"Europe/Paris", "--job-file " + jobFile, func(t *testing.T, args []string) []string {
+			return append(args, "--job-file", jobFile)
+		}},
+		{"Europe/London", "--job", func(t *testing.T, args []string) []string {
+			return append(args, "--job", `{"timeZone": "Europe/London"}`)
+		}},
+		{"Australia/Sydney", "--timezone", func(t *testing.T, args []string) []string {
+			return append(args, "--timezone", "Australia/Sydney")
+		}},
+	}
+	for top := range layers {
+		t.Run(layers[top].source, func(t *testing.T) {
+			isolateJobSources(t)
+			args := []string{"-q", "error", "-f", "2024-01-01T00:00:00", "-t", "2024-01-01T01:00:00"}
+			for _, layer := range layers[:top+1] {
+				args = layer.apply(t, args)
+			}
+			jobDef, sources := resolveArgs(t, args...)
+			if jobDef.Timezone != layers[top].timeZone {
+				t.Errorf("timeZone = %q, want %q", jobDef.Timezone, layers[top].timeZone)
+			}
+			if sources["timeZone"] != layers[top].source {
+				t.Errorf("timeZone source = %q, want %q", sources["timeZone"], layers[top].source)
+			}
+		})
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"os"
 	"time"
 
-	"github.com/nhoag/sumo-search-job-cli/client"
 	"github.com/spf13/cobra"
 )
 
@@ -22,7 +21,7 @@ var jobDeleteCmd = &cobra.Command{
 		if VerboseOpt {
 			fmt.Fprintf(os.Stderr, "%d\tSTART\tjobDelete\n", time.Now().UnixNano())
 		}
-		executeDelete(cmd, args)
+		exitOnError(executeDelete(cmd, args))
 		if VerboseOpt {
 			fmt.Fprintf(os.Stderr, "%d\tEND\tjobDelete\n", time.Now().UnixNano())
 		}
@@ -39,17 +38,20 @@ func validateDelete() {
 	}
 }
 
-func executeDelete(cmd *cobra.Command, args []string) {
+func executeDelete(cmd *cobra.Command, args []string) error {
 	if VerboseOpt {
 		fmt.Fprintf(os.Stderr, "%d\tSTART\tjobDelete::executeDelete()\n", time.Now().UnixNano())
 	}
-	client.DeleteSearchJob(args[0])
+	if err := deleteJob(cmd.Context(), args[0]); err != nil {
+		return err
+	}
 	if !QuietOpt {
 		fmt.Fprintf(os.Stderr, "Successfully Deleted Search Job!\n")
 	}
 	if VerboseOpt {
 		fmt.Fprintf(os.Stderr, "%d\tEND\tjobDelete::executeDelete()\n", time.Now().UnixNano())
 	}
+	return nil
 }
 
 func init() {
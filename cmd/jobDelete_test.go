@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nhoag/sumo-search-job-cli/fakeserver"
+)
+
+func TestJobDelete(t *testing.T) {
+	api := newFakeAPI(t, fakeserver.Fixture{})
+	jobId := api.createJob(t, "error")
+	_, stderr, code := runCLI(t, api.args("jobDelete", jobId)...)
+	if code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	if !strings.Contains(stderr, "Successfully Deleted Search Job!") {
+		t.Errorf("stderr %q doesn't report the deletion", stderr)
+	}
+	if ids := api.JobIds(); len(ids) != 0 {
+		t.Errorf("search jobs %v were not deleted", ids)
+	}
+
+	_, stderr, code = runCLI(t, api.args("jobDelete", jobId)...)
+	if code != 1 {
+		t.Errorf("exit code %d deleting a deleted job, want 1", code)
+	}
+	if !strings.Contains(stderr, "Job ID is invalid") {
+		t.Errorf("stderr %q doesn't report the unknown job", stderr)
+	}
+}
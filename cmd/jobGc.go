@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	OlderThanMinutesOpt int
+	DryRunOpt           bool
+)
+
+// jobGcCmd represents the jobGc command
+var jobGcCmd = &cobra.Command{
+	Use:   "jobGc",
+	Short: "Delete stale Sumo Logic Search Jobs created by this CLI",
+	Long: `The jobGc command deletes every search job in the local job registry
+	that is older than --older-than minutes or has reached a terminal state
+	(DONE GATHERING RESULTS, CANCELLED or FORCE PAUSED). Jobs that no longer
+	exist are removed from the registry. Jobs created with another endpoint or
+	profile are left alone.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		QuietOpt, _ = cmd.Flags().GetBool("quiet")
+		VerboseOpt, _ = cmd.Flags().GetBool("verbose")
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tSTART\tjobGc\n", time.Now().UnixNano())
+		}
+		validateJobGc()
+		exitOnError(executeJobGc(cmd, args))
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tEND\tjobGc\n", time.Now().UnixNano())
+		}
+	},
+}
+
+func validateJobGc() {
+	if OlderThanMinutesOpt < 0 {
+		cobra.CheckErr(fmt.Errorf("older-than must not be negative"))
+	}
+}
+
+func executeJobGc(cmd *cobra.Command, args []string) error {
+	if VerboseOpt {
+		fmt.Fprintf(os.Stderr, "%d\tSTART\tjobGc::executeJobGc()\n", time.Now().UnixNano())
+	}
+	jobs, err := registeredJobs()
+	if err != nil {
+		return err
+	}
+	maxAge := time.Duration(OlderThanMinutesOpt) * time.Minute
+	var expired []string
+	deleted, failed := 0, 0
+	for _, job := range jobs {
+		if !reachableJob(job) {
+			if VerboseOpt {
+				fmt.Fprintf(os.Stderr, "Skipping search job %s from endpoint %s, profile %q\n", job.ID, job.Endpoint, job.Profile)
+			}
+			continue
+		}
+		status, state, err := registeredJobStatus(cmd.Context(), job)
+		if err != nil {
+			return err
+		}
+		var reason string
+		switch {
+		case state == "EXPIRED":
+			expired = append(expired, job.ID)
+			continue
+		case status != nil && jobDone(status):
+			reason = state
+		case time.Since(job.Created) > maxAge:
+			reason = fmt.Sprintf("older than %s", maxAge)
+		default:
+			continue
+		}
+		if DryRunOpt {
+			fmt.Fprintf(os.Stderr, "Would delete search job %s (%s)\n", job.ID, reason)
+			continue
+		}
+		if err := deleteJob(cmd.Context(), job.ID); err != nil {
+			if cmd.Context().Err() != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Unable to delete search job %s: %v\n", job.ID, err)
+			failed++
+			continue
+		}
+		deleted++
+		if !QuietOpt {
+			fmt.Fprintf(os.Stderr, "Deleted search job %s (%s)\n", job.ID, reason)
+		}
+	}
+	if len(expired) > 0 && !DryRunOpt {
+		unregisterJobs(expired...)
+	}
+	if !QuietOpt {
+		verb := "Forgot"
+		if DryRunOpt {
+			verb = "Would forget"
+		}
+		for _, jobId := range expired {
+			fmt.Fprintf(os.Stderr, "%s expired search job %s\n", verb, jobId)
+		}
+		if !DryRunOpt {
+			fmt.Fprintf(os.Stderr, "Deleted %d search jobs, forgot %d expired\n", deleted, len(expired))
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("unable to delete %d search jobs", failed)
+	}
+	if VerboseOpt {
+		fmt.Fprintf(os.Stderr, "%d\tEND\tjobGc::executeJobGc()\n", time.Now().UnixNano())
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(jobGcCmd)
+	jobGcCmd.Flags().IntVar(&OlderThanMinutesOpt, "older-than", 60, "Delete jobs created more than this many minutes ago")
+	jobGcCmd.Flags().BoolVar(&DryRunOpt, "dry-run", false, "Only print which jobs would be deleted")
+}
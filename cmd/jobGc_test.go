@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nhoag/sumo-search-job-cli/fakeserver"
+	"github.com/nhoag/sumo-search-job-cli/registry"
+)
+
+func TestJobGc(t *testing.T) {
+	fixture := fakeserver.Fixture{Scenarios: []fakeserver.Scenario{
+		{Match: "done", States: []fakeserver.Step{{State: "DONE GATHERING RESULTS"}}},
+		{Match: "paused", States: []fakeserver.Step{{State: "FORCE PAUSED"}}},
+		{Match: "cancelled", States: []fakeserver.Step{{State: "CANCELLED"}}},
+		{States: []fakeserver.Step{{State: "GATHERING RESULTS"}}},
+	}}
+	tests := []struct {
+		name   string
+		args   []string
+		live   []string
+		jobs   []string
+		stderr []string
+	}{
+		{
+			name: "delete",
+			// Running young jobs and jobs of other endpoints are kept.
+			live: []string{"running"},
+			jobs: []string{"running", "other endpoint"},
+			stderr: []string{
+				"Deleted search job FAKE000000000002 (DONE GATHERING RESULTS)",
+				"Deleted search job FAKE000000000003 (FORCE PAUSED)",
+				"Deleted search job FAKE000000000004 (CANCELLED)",
+				"Deleted search job FAKE000000000005 (older than 1h0m0s)",
+				"Forgot expired search job FAKE000000000006",
+				"Deleted 4 search jobs, forgot 1 expired",
+			},
+		},
+		{
+			name: "older than",
+			args: []string{"--older-than", "0"},
+			jobs: []string{"other endpoint"},
+			stderr: []string{
+				"Deleted search job FAKE000000000001 (older than 0s)",
+				"Deleted search job FAKE000000000005 (older than 0s)",
+				"Deleted 5 search jobs, forgot 1 expired",
+			},
+		},
+		{
+			name: "dry run",
+			args: []string{"--dry-run"},
+			live: []string{"running", "done", "paused", "cancelled", "old"},
+			jobs: []string{"running", "done", "paused", "cancelled", "old", "expired", "other endpoint"},
+			stderr: []string{
+				"Would delete search job FAKE000000000002 (DONE GATHERING RESULTS)",
+				"Would delete search job FAKE000000000005 (older than 1h0m0s)",
+				"Would forget expired search job FAKE000000000006",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakeAPI(t, fixture)
+			home := t.TempDir()
+			jobs := registry.Open(filepath.Join(home, ".config", "sumo-search-job-cli", "jobs.json"))
+			names := make(map[string]string)
+			for _, job := range []struct {
+				query string
+				age   time.Duration
+			}{
+				{"running", time.Minute},
+				{"done", time.Minute},
+				{"paused", time.Minute},
+				{"cancelled", time.Minute},
+				{"old", 2 * time.Hour},
+				{"expired", time.Minute},
+			} {
+				jobId := api.createJob(t, job.query)
+				names[jobId] = job.query
+				if err := jobs.Add(registry.Job{ID: jobId, Query: job.query, Endpoint: api.endpoint, Created: time.Now().Add(-job.age)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			api.deleteJob(t, "FAKE000000000006")
+			// Jobs of other endpoints are never looked up.
+			if err := jobs.Add(registry.Job{ID: "OTHER", Query: "other endpoint", Endpoint: "https://api.example.com/api", Created: time.Now().Add(-2 * time.Hour)}); err != nil {
+				t.Fatal(err)
+			}
+
+			run := startCLI(t, home, api.args(append([]string{"jobGc"}, tt.args...)...)...)
+			if code := run.wait(t); code != 0 {
+				t.Fatalf("exit code %d: %s", code, run.stderr.String())
+			}
+			stderr := run.stderr.String()
+			for _, want := range tt.stderr {
+				if !strings.Contains(stderr, want) {
+					t.Errorf("stderr %q doesn't contain %q", stderr, want)
+				}
+			}
+			if strings.Contains(stderr, "OTHER") {
+				t.Errorf("stderr %q mentions the job of another endpoint", stderr)
+			}
+
+			var live []string
+			for _, jobId := range api.JobIds() {
+				live = append(live, names[jobId])
+			}
+			if got, want := sortedNames(live), sortedNames(tt.live); got != want {
+				t.Errorf("live search jobs %s, want %s", got, want)
+			}
+			list, err := jobs.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var registered []string
+			for _, job := range list {
+				registered = append(registered, job.Query)
+			}
+			if got, want := sortedNames(registered), sortedNames(tt.jobs); got != want {
+				t.Errorf("registered search jobs %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// sortedNames returns the sorted names joined by commas.
+func sortedNames(names []string) string {
+	names = append([]string(nil), names...)
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
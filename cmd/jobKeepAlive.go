@@ -1,17 +1,28 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/nhoag/sumo-search-job-cli/client"
 )
 
+// keepAliveInterval is the default jobKeepAlive interval, well within the
+// five minute expiry. --ephemeral and long result fetches refresh jobs at
+// this interval too, unless --keep-alive-interval is set.
+const keepAliveInterval = 30 * time.Second
+
 var (
 	DurationMinutes int32
 	IntervalSeconds int32
 	RequestCount    int32
+
+	KeepAliveIntervalOpt time.Duration
 )
 
 // jobKeepAliveCmd represents the jobKeepAlive command
@@ -19,13 +30,14 @@ var jobKeepAliveCmd = &cobra.Command{
 	Use:   "jobKeepAlive JOB_ID",
 	Short: "Issue periodic keep-alive job status request",
 	Long:  `Keep a Search Job alive by issuing periodic status requests.`,
+	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		QuietOpt, _ = cmd.Flags().GetBool("quiet")
 		VerboseOpt, _ = cmd.Flags().GetBool("verbose")
 		if VerboseOpt {
 			fmt.Fprintf(os.Stderr, "%d\tSTART\tjobKeepAlive\n", time.Now().UnixNano())
 		}
-		executeKeepAlive(cmd, args)
+		exitOnError(executeKeepAlive(cmd, args))
 		if VerboseOpt {
 			fmt.Fprintf(os.Stderr, "%d\tEND\tjobKeepAlive\n", time.Now().UnixNano())
 		}
@@ -42,7 +54,15 @@ func validateKeepAlive() {
 	}
 }
 
-func executeKeepAlive(cmd *cobra.Command, args []string) {
+// validateKeepAliveInterval checks --keep-alive-interval, which must be
+// shorter than the five minute expiry to be of any use.
+func validateKeepAliveInterval() {
+	if KeepAliveIntervalOpt <= 0 || KeepAliveIntervalOpt >= 5*time.Minute {
+		cobra.CheckErr(fmt.Errorf("keep-alive-interval must be between 0 and 5m"))
+	}
+}
+
+func executeKeepAlive(cmd *cobra.Command, args []string) error {
 	if VerboseOpt {
 		fmt.Fprintf(os.Stderr, "%d\tSTART\tjobKeepAlive::executeKeepAlive()\n", time.Now().UnixNano())
 	}
@@ -50,24 +70,85 @@ func executeKeepAlive(cmd *cobra.Command, args []string) {
 	iterations := int32(1)
 	start := time.Now().Unix()
 	for {
-		executeStatusCheck(cmd, args)
+		if _, err := executeStatusCheck(cmd, args); err != nil {
+			return err
+		}
 		if !forever &&
 			(iterations >= RequestCount ||
 				time.Now().Unix()-start > int64(DurationMinutes)*60) {
 			break
 		}
 		iterations = iterations + int32(1)
-		time.Sleep(time.Duration(IntervalSeconds) * time.Second)
+		if err := sleep(cmd.Context(), time.Duration(IntervalSeconds)*time.Second); err != nil {
+			return err
+		}
 	}
 
 	if VerboseOpt {
 		fmt.Fprintf(os.Stderr, "%d\tEND\tjobKeepAlive::executeKeepAlive()\n", time.Now().UnixNano())
 	}
+	return nil
+}
+
+// backgroundKeepAlive refreshes a search job while its results are fetched,
+// since writing them to a slow consumer can outlast the job's expiry.
+type backgroundKeepAlive struct {
+	cancel  context.CancelFunc
+	done    chan struct{}
+	expired error
+}
+
+// startKeepAlive requests the status of a search job every
+// --keep-alive-interval until stop is called.
+func startKeepAlive(ctx context.Context, jobId string) *backgroundKeepAlive {
+	ctx, cancel := context.WithCancel(ctx)
+	k := &backgroundKeepAlive{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(k.done)
+		for {
+			if err := sleep(ctx, KeepAliveIntervalOpt); err != nil {
+				return
+			}
+			_, err := getClient().GetSearchJobStatus(ctx, jobId)
+			switch {
+			case ctx.Err() != nil:
+				return
+			case client.IsNotFound(err):
+				k.expired = fmt.Errorf("search job %s expired while its results were being fetched: %w", jobId, err)
+				if !QuietOpt {
+					fmt.Fprintf(os.Stderr, "Search job %s has expired\n", jobId)
+				}
+				return
+			case err != nil:
+				// A failed keep-alive is retried on the next interval.
+				if !QuietOpt {
+					fmt.Fprintf(os.Stderr, "Unable to keep search job %s alive: %v\n", jobId, err)
+				}
+			case VerboseOpt:
+				fmt.Fprintf(os.Stderr, "%d\tKEEP ALIVE:\t%s\n", time.Now().UnixNano(), jobId)
+			}
+		}
+	}()
+	return k
+}
+
+// stop ends the keep-alive requests and returns an error if the job expired
+// in the meantime.
+func (k *backgroundKeepAlive) stop() error {
+	k.cancel()
+	<-k.done
+	return k.expired
+}
+
+// addKeepAliveFlags adds --keep-alive-interval to commands that keep a job
+// alive while they run.
+func addKeepAliveFlags(flags *pflag.FlagSet) {
+	flags.DurationVar(&KeepAliveIntervalOpt, "keep-alive-interval", keepAliveInterval, "Interval of the status requests that keep the search job from expiring")
 }
 
 func init() {
 	rootCmd.AddCommand(jobKeepAliveCmd)
-	jobKeepAliveCmd.Flags().Int32VarP(&IntervalSeconds, "interval", "i", 30, "Keep-alive interval in seconds")
+	jobKeepAliveCmd.Flags().Int32VarP(&IntervalSeconds, "interval", "i", int32(keepAliveInterval/time.Second), "Keep-alive interval in seconds")
 	jobKeepAliveCmd.Flags().Int32VarP(&DurationMinutes, "duration", "k", 30, "Keep-alive duration in minutes")
 	jobKeepAliveCmd.Flags().Int32VarP(&RequestCount, "count", "c", 10, "Keep-alive request count")
 	jobKeepAliveCmd.Flags().BoolP("forever", "f", false, "Issue keep-alive requests indefinitely")
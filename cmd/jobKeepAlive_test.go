@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nhoag/sumo-search-job-cli/fakeserver"
+)
+
+func TestJobKeepAlive(t *testing.T) {
+	api := newFakeAPI(t, fakeserver.Fixture{Scenarios: []fakeserver.Scenario{
+		{States: []fakeserver.Step{{State: "GATHERING RESULTS"}}},
+	}})
+	jobId := api.createJob(t, "error")
+	_, stderr, code := runCLI(t, api.args("jobKeepAlive", jobId, "-c", "3", "-i", "0")...)
+	if code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	if polls := strings.Count(stderr, "Status:\t\tGATHERING RESULTS"); polls != 3 {
+		t.Errorf("got %d status requests, want 3", polls)
+	}
+
+	api.deleteJob(t, jobId)
+	_, stderr, code = runCLI(t, api.args("jobKeepAlive", jobId, "-c", "3", "-i", "0")...)
+	if code != 1 {
+		t.Errorf("exit code %d for an expired job, want 1", code)
+	}
+	if !strings.Contains(stderr, "Job ID is invalid") {
+		t.Errorf("stderr %q doesn't report the expired job", stderr)
+	}
+}
+
+func TestKeepAliveDuringFetch(t *testing.T) {
+	scenario := messageScenario("", 20)
+	scenario.States = []fakeserver.Step{{State: "DONE GATHERING RESULTS"}}
+	tests := []struct {
+		name   string
+		fault  *fakeserver.Fault
+		code   int
+		stdout int
+		stderr []string
+		// requests is the least number of status requests, the job's poll
+		// included.
+		requests int
+	}{
+		{name: "kept alive", stdout: 20, requests: 4},
+		// The first status request is the job's poll; the keep-alive
+		// requests come after it.
+		{
+			name:     "expired",
+			fault:    &fakeserver.Fault{Op: "status", Status: 404, After: 1},
+			code:     1,
+			stdout:   20,
+			stderr:   []string{"Search job FAKE000000000001 has expired", "Error: search job FAKE000000000001 expired while its results were being fetched"},
+			requests: 2,
+		},
+		{
+			// A failed request is retried on the next interval.
+			name:     "failed once",
+			fault:    &fakeserver.Fault{Op: "status", Status: 500, After: 1, Count: 1},
+			stdout:   20,
+			stderr:   []string{"Unable to keep search job FAKE000000000001 alive: GetSearchJobStatus: HTTP 500"},
+			requests: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakeAPI(t, fakeserver.Fixture{Scenarios: []fakeserver.Scenario{scenario}})
+			jobId := api.createJob(t, "error")
+			if tt.fault != nil {
+				api.AddFault(*tt.fault)
+			}
+			// Two pages a second apart outlast several keep-alive
+			// intervals.
+			stdout, stderr, code := runCLI(t, api.args("jobResultsGet", jobId, "-a", "-m", "-l", "10", "-O", "ndjson",
+				"--parallel", "1", "--sleep", "1", "--keep-alive-interval", "100ms", "--retry-max-attempts", "1")...)
+			if code != tt.code {
+				t.Errorf("exit code %d, want %d: %s", code, tt.code, stderr)
+			}
+			if lines := strings.Count(stdout, "\n"); lines != tt.stdout {
+				t.Errorf("got %d messages, want %d", lines, tt.stdout)
+			}
+			for _, want := range tt.stderr {
+				if !strings.Contains(stderr, want) {
+					t.Errorf("stderr %q doesn't contain %q", stderr, want)
+				}
+			}
+			if requests := api.Requests("status"); requests < tt.requests {
+				t.Errorf("got %d status requests, want at least %d", requests, tt.requests)
+			}
+		})
+	}
+}
+
+func TestKeepAliveIntervalValidation(t *testing.T) {
+	for _, interval := range []string{"0s", "5m"} {
+		_, stderr, code := runCLI(t, "jobResultsGet", "FAKE000000000001", "--keep-alive-interval", interval)
+		if code != 1 || !strings.Contains(stderr, "keep-alive-interval must be between 0 and 5m") {
+			t.Errorf("--keep-alive-interval %s: exit code %d with %q", interval, code, stderr)
+		}
+	}
+}
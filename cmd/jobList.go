@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	openapi "github.com/nhoag/sumologic-search-job-client-go"
+
+	"github.com/nhoag/sumo-search-job-cli/client"
+	"github.com/nhoag/sumo-search-job-cli/registry"
+)
+
+var (
+	ListOutputOpt string
+	NoStatusOpt   bool
+)
+
+// jobListFields are the columns printed by jobList.
+var jobListFields = []client.Field{
+	{Name: "id"}, {Name: "created"}, {Name: "age"}, {Name: "state"},
+	{Name: "messages"}, {Name: "records"}, {Name: "deployment"}, {Name: "profile"},
+	{Name: "from"}, {Name: "to"}, {Name: "query"},
+}
+
+// jobListCmd represents the jobList command
+var jobListCmd = &cobra.Command{
+	Use:   "jobList",
+	Short: "List the Sumo Logic Search Jobs created by this CLI",
+	Long: `The jobList command lists the search jobs recorded in the local job
+	registry, along with their live status from the Search Job API. Jobs are
+	recorded when they are created and forgotten when they are deleted.
+	Jobs that no longer exist are shown as EXPIRED.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		QuietOpt, _ = cmd.Flags().GetBool("quiet")
+		VerboseOpt, _ = cmd.Flags().GetBool("verbose")
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tSTART\tjobList\n", time.Now().UnixNano())
+		}
+		exitOnError(validateOutputFormat(ListOutputOpt))
+		exitOnError(executeJobList(cmd, args))
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tEND\tjobList\n", time.Now().UnixNano())
+		}
+	},
+}
+
+func executeJobList(cmd *cobra.Command, args []string) error {
+	if VerboseOpt {
+		fmt.Fprintf(os.Stderr, "%d\tSTART\tjobList::executeJobList()\n", time.Now().UnixNano())
+	}
+	jobs, err := registeredJobs()
+	if err != nil {
+		return err
+	}
+	writer, err := newResultWriter(os.Stdout, ListOutputOpt)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	rows := make([]client.Row, 0, len(jobs))
+	for _, job := range jobs {
+		row := client.Row{
+			"id":         job.ID,
+			"created":    job.Created.Local().Format(time.RFC3339),
+			"age":        now.Sub(job.Created).Round(time.Second).String(),
+			"deployment": job.Deployment,
+			"profile":    job.Profile,
+			"from":       job.From,
+			"to":         job.To,
+			"query":      job.Query,
+		}
+		if !NoStatusOpt {
+			status, state, err := registeredJobStatus(cmd.Context(), job)
+			if err != nil {
+				return err
+			}
+			row["state"] = state
+			if status != nil {
+				row["messages"] = strconv.Itoa(int(status.GetMessageCount()))
+				row["records"] = strconv.Itoa(int(status.GetRecordCount()))
+			}
+		}
+		rows = append(rows, row)
+	}
+	if err := writer.WritePage(jobListFields, rows); err != nil {
+		return err
+	}
+	if err := writer.EndSection(); err != nil {
+		return err
+	}
+	if !QuietOpt && len(jobs) == 0 {
+		fmt.Fprintf(os.Stderr, "No search jobs recorded\n")
+	}
+	if VerboseOpt {
+		fmt.Fprintf(os.Stderr, "%d\tEND\tjobList::executeJobList()\n", time.Now().UnixNano())
+	}
+	return nil
+}
+
+// registeredJobs returns the jobs in the local job registry.
+func registeredJobs() ([]registry.Job, error) {
+	jobs := getRegistry()
+	if jobs == nil {
+		return nil, fmt.Errorf("unable to locate the job registry; set registry_file in the config")
+	}
+	return jobs.List()
+}
+
+// reachableJob reports whether a registered job was created with the
+// endpoint and profile in use, i.e. whether its status can be looked up.
+func reachableJob(job registry.Job) bool {
+	return job.Endpoint == getClient().Endpoint() && job.Profile == viper.GetString("profile")
+}
+
+// registeredJobStatus looks up the live status of a registered job. The
+// returned state is the job state, EXPIRED when the job no longer exists, or
+// UNKNOWN when the job belongs to another endpoint or profile. Only errors
+// that abort the listing, such as a cancelled context, are returned.
+func registeredJobStatus(ctx context.Context, job registry.Job) (*openapi.SearchJobState, string, error) {
+	if !reachableJob(job) {
+		return nil, "UNKNOWN", nil
+	}
+	status, err := getClient().GetSearchJobStatus(ctx, job.ID)
+	if client.IsNotFound(err) {
+		return nil, "EXPIRED", nil
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, "", err
+		}
+		if !QuietOpt {
+			fmt.Fprintf(os.Stderr, "Unable to get status of search job %s: %v\n", job.ID, err)
+		}
+		return nil, "ERROR", nil
+	}
+	return status, status.GetState(), nil
+}
+
+func init() {
+	rootCmd.AddCommand(jobListCmd)
+	jobListCmd.Flags().StringVarP(&ListOutputOpt, "output", "O", "table", "Output format: json, ndjson, csv, tsv or table")
+	jobListCmd.Flags().BoolVar(&NoStatusOpt, "no-status", false, "Don't look up the live status of each job")
+}
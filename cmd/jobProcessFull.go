@@ -3,9 +3,11 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 // jobProcessFullCmd represents the jobProcessFull command
@@ -15,70 +17,94 @@ var jobProcessFullCmd = &cobra.Command{
 	Long: `The jobProcessFull command will create a search job, poll for
 	completion, fetch the results, and delete a Sumo Logic Search Job via the
 	Search Job API.`,
+	PreRun: preparePolling,
 	Run: func(cmd *cobra.Command, args []string) {
 		QuietOpt, _ = cmd.Flags().GetBool("quiet")
 		VerboseOpt, _ = cmd.Flags().GetBool("verbose")
 		if VerboseOpt {
 			fmt.Fprintf(os.Stderr, "%d\tSTART\tjobProcessFull\n", time.Now().UnixNano())
 		}
-		validateProcessFull()
-		executeProcessFull(cmd, args)
+		validateProcessFull(cmd)
+		if PrintJobOpt {
+			exitOnError(executePrintJob(cmd, args))
+			return
+		}
+		exitOnError(executeProcessFull(cmd, args))
 		if VerboseOpt {
 			fmt.Fprintf(os.Stderr, "%d\tEND\tjobProcessFull\n", time.Now().UnixNano())
 		}
 	},
 }
 
-func validateProcessFull() {
+func validateProcessFull(cmd *cobra.Command) {
 	if VerboseOpt {
 		fmt.Fprintf(os.Stderr, "%d\tSTART\tjobProcessFull::validateProcessFull()\n", time.Now().UnixNano())
 	}
-	validateJobCreate()
 	validateStatusCheck()
 	validateJobResults()
 	validateDelete()
+	validateSplit(cmd)
+	validateSweep()
 	if VerboseOpt {
 		fmt.Fprintf(os.Stderr, "%d\tEND\tjobProcessFull::validateProcessFull()\n", time.Now().UnixNano())
 	}
 }
 
-func executeProcessFull(cmd *cobra.Command, args []string) {
+func executeProcessFull(cmd *cobra.Command, args []string) error {
 	if VerboseOpt {
 		fmt.Fprintf(os.Stderr, "%d\tSTART\tjobProcessFull::executeProcessFull()\n", time.Now().UnixNano())
 	}
-	_, jobId := executeSearchJob(buildPayload(cmd, args))
+	if len(SweepOpt) > 0 {
+		return executeSweep(cmd, args)
+	}
+	jobDef, err := buildPayload(cmd, args)
+	if err != nil {
+		return err
+	}
+	if len(SplitOpt) > 0 {
+		return executeSplitProcess(cmd, jobDef)
+	}
+	_, jobId, err := executeSearchJob(cmd.Context(), jobDef)
+	if err != nil {
+		return err
+	}
 	// Add Job ID as first arg for subsequent function calls.
 	args = append([]string{jobId}, args...)
-	executeJobResults(cmd, args)
-	executeDelete(cmd, args)
+	// On failure the job is deleted by exitOnError.
+	if err := executeJobResults(cmd, args); err != nil {
+		return err
+	}
+	if err := executeDelete(cmd, args); err != nil {
+		return err
+	}
 	if VerboseOpt {
 		fmt.Fprintf(os.Stderr, "%d\tEND\tjobProcessFull::executeProcessFull()\n", time.Now().UnixNano())
 	}
+	return nil
+}
+
+// processFullFlagSets returns the flags of jobProcessFull and of 'query run',
+// which takes the same flags.
+func processFullFlagSets() []*pflag.FlagSet {
+	return []*pflag.FlagSet{jobProcessFullCmd.Flags(), queryRunCmd.Flags()}
 }
 
 func init() {
 	rootCmd.AddCommand(jobProcessFullCmd)
 
-	jobProcessFullCmd.Flags().StringVarP(&JobOpt, "job", "j", "", "Search job definition")
-	jobProcessFullCmd.Flags().StringVarP(&JobFileOpt, "job-file", "J", "", "Path to file with full search job definition")
+	for _, flags := range processFullFlagSets() {
+		addJobDefinitionFlags(flags)
+		addKeepAliveFlags(flags)
+		flags.BoolVar(&PrintJobOpt, "print-job", false, "Print the effective job definition and the source of each field, without running the job")
 
-	jobProcessFullCmd.Flags().StringVarP(&QueryOpt, "query", "q", "", "Search query")
-	jobProcessFullCmd.Flags().StringVarP(&QueryFileOpt, "query-file", "Q", "", "Path to file with search query")
-
-	jobProcessFullCmd.Flags().StringVarP(&DurationOpt, "duration", "d", "", "Size of time span relative to now (e.g. -3h)")
-	// @todo: Add FromTimeMillis option: milliseconds since epoch.
-	jobProcessFullCmd.Flags().StringVarP(&FromTimeOpt, "from", "f", "", "Search window start time (e.g. 2017-07-16T00:00:00)")
-	// @todo: Add ToTimeMillis option: milliseconds since epoch.
-	jobProcessFullCmd.Flags().StringVarP(&ToTimeOpt, "to", "t", "", "Search window end time (e.g. 2017-07-16T00:00:00)")
-	jobProcessFullCmd.Flags().StringVarP(&TimeZoneOpt, "timezone", "z", "UTC", "Timezone to use for search window")
-	jobProcessFullCmd.Flags().BoolP("by-receipt-time", "b", false, "Use receipt-time instead of log message timestamps")
-	jobProcessFullCmd.Flags().StringVarP(&AutoParsingModeOpt, "auto-parse", "A", "", "Specify auto-parsing mode to use ('intelligent' automatically runs field extraction rules)")
-
-	jobProcessFullCmd.Flags().BoolP("records", "r", false, "Retrieve records only")
-	jobProcessFullCmd.Flags().BoolP("messages", "m", false, "Retrieve messages only")
-	jobProcessFullCmd.Flags().BoolP("all", "a", true, "Retrieve all paginated results")
-	jobProcessFullCmd.Flags().Int32VarP(&LimitOpt, "limit", "l", 100, "Specify pagination limit")
-	jobProcessFullCmd.Flags().Int32VarP(&OffsetOpt, "offset", "o", 0, "Specify pagination offset")
-	jobProcessFullCmd.Flags().BoolP("poll", "p", true, "Poll for status until search job is complete")
-	jobProcessFullCmd.Flags().Int32VarP(&SleepSecondsOpt, "sleep", "Z", 1, "Specify sleep seconds")
+		flags.BoolP("records", "r", false, "Retrieve records only")
+		flags.BoolP("messages", "m", false, "Retrieve messages only")
+		flags.BoolP("all", "a", true, "Retrieve all paginated results")
+		flags.Int32VarP(&LimitOpt, "limit", "l", 0, "Specify pagination limit, up to 10000 (default chooses the page size)")
+		flags.Int32VarP(&OffsetOpt, "offset", "o", 0, "Specify pagination offset")
+		flags.BoolP("poll", "p", true, "Poll for status until search job is complete")
+		flags.Int32VarP(&SleepSecondsOpt, "sleep", "Z", 1, "Fixed seconds between status polls, instead of backing off, and between result pages, which are then fetched one at a time")
+		flags.BoolVar(&KeepOnInterruptOpt, "keep-on-interrupt", false, "Don't delete the search job when interrupted")
+		flags.StringVarP(&OutputOpt, "output", "O", "json", "Output format ("+strings.Join(OutputFormats, ", ")+")")
+	}
 }
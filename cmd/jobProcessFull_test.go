@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nhoag/sumo-search-job-cli/fakeserver"
+)
+
+// runCommand runs the CLI with args against a home directory of its own and
+// returns what it wrote to stdout.
+func runCommand(t *testing.T, args ...string) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+
+	out, err := os.Create(filepath.Join(home, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	stdout := os.Stdout
+	os.Stdout = out
+	defer func() { os.Stdout = stdout }()
+
+	rootCmd.SetArgs(args)
+	if err := rootCmd.ExecuteContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestJobProcessFull(t *testing.T) {
+	scenario := fakeserver.Scenario{
+		Match: "error",
+		States: []fakeserver.Step{
+			{State: "GATHERING RESULTS", Polls: 2},
+			{State: "DONE GATHERING RESULTS"},
+		},
+		Fields: []fakeserver.Field{{Name: "_raw", FieldType: "string"}},
+	}
+	for i := 0; i < 250; i++ {
+		scenario.Messages = append(scenario.Messages, map[string]string{"_raw": fmt.Sprintf("m%d", i)})
+	}
+	server := fakeserver.New(fakeserver.Config{Fixture: fakeserver.Fixture{Scenarios: []fakeserver.Scenario{scenario}}})
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+
+	stdout := runCommand(t, "jobProcessFull",
+		"--endpoint", srv.URL+"/api", "--rate-limit", "0", "-S",
+		"-q", "error", "-f", "2024-01-01T00:00:00", "-t", "2024-01-01T01:00:00",
+		"-m", "-l", "100", "-O", "ndjson", "--poll-interval", "1ms")
+
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 250 {
+		t.Fatalf("got %d messages, want 250", len(lines))
+	}
+	for i, line := range lines {
+		var row map[string]string
+		if err := json.Unmarshal([]byte(line), &row); err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+		if want := fmt.Sprintf("m%d", i); row["_raw"] != want {
+			t.Fatalf("line %d: _raw = %q, want %q", i+1, row["_raw"], want)
+		}
+	}
+	if ids := server.JobIds(); len(ids) != 0 {
+		t.Errorf("search jobs %v were not deleted", ids)
+	}
+}
+
+func TestJobProcessFullScenarios(t *testing.T) {
+	paused := messageScenario("pause", 20)
+	paused.States = []fakeserver.Step{{State: "GATHERING RESULTS"}, {State: "FORCE PAUSED"}}
+	cancelled := messageScenario("cancel", 20)
+	cancelled.States = []fakeserver.Step{{State: "GATHERING RESULTS"}, {State: "CANCELLED"}}
+	fixture := fakeserver.Fixture{Scenarios: []fakeserver.Scenario{
+		paused,
+		cancelled,
+		{Match: "warn", States: []fakeserver.Step{{State: "DONE GATHERING RESULTS"}}, PendingWarnings: []string{"Field foo not found"}},
+		messageScenario("", 20),
+	}}
+	tests := []struct {
+		name   string
+		query  string
+		args   []string
+		faults []fakeserver.Fault
+		code   int
+		stdout int
+		stderr []string
+		// live is the number of search jobs left behind.
+		live int
+	}{
+		{name: "done", query: "error", stdout: 20, stderr: []string{"Status:\t\tDONE GATHERING RESULTS", "Successfully Deleted Search Job!"}},
+		// A paused job returns the messages gathered until it was paused.
+		{name: "force paused", query: "pause", stdout: 20, stderr: []string{"Status:\t\tFORCE PAUSED", "Successfully Deleted Search Job!"}},
+		{name: "cancelled", query: "cancel", code: 1, stderr: []string{"Error: search job FAKE000000000001 was cancelled", "Deleted search job FAKE000000000001"}},
+		{name: "warnings", query: "warn", stderr: []string{"Warning:\tField foo not found"}},
+		{name: "warnings as errors", query: "warn", args: []string{"--warnings-as-errors"}, code: 1, stderr: []string{"Error: search job FAKE000000000001 reported warnings: Field foo not found"}},
+		{name: "create fault", query: "error", faults: []fakeserver.Fault{{Op: "create", Status: 500}}, code: 1, stderr: []string{"Error: CreateSearchJob: HTTP 500"}},
+		{name: "status fault", query: "error", faults: []fakeserver.Fault{{Op: "status", Status: 503, Count: 2}}, stdout: 20, stderr: []string{"HTTP 503", "Successfully Deleted Search Job!"}},
+		{name: "messages fault", query: "error", faults: []fakeserver.Fault{{Op: "messages", Status: 500}}, code: 1, stderr: []string{"Error: GetSearchJobMessages: HTTP 500", "Deleted search job FAKE000000000001"}},
+		{name: "delete fault", query: "error", faults: []fakeserver.Fault{{Op: "delete", Status: 500}}, code: 1, stdout: 20, stderr: []string{"Error: DeleteSearchJob: HTTP 500"}, live: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakeAPI(t, fixture)
+			for _, fault := range tt.faults {
+				api.AddFault(fault)
+			}
+			args := append([]string{"jobProcessFull", "-q", tt.query, "-d", "1h", "-m", "-O", "ndjson", "--poll-interval", "1ms", "--retry-max-attempts", "3"}, tt.args...)
+			stdout, stderr, code := runCLI(t, api.args(args...)...)
+			if code != tt.code {
+				t.Errorf("exit code %d, want %d: %s", code, tt.code, stderr)
+			}
+			if lines := strings.Count(stdout, "\n"); lines != tt.stdout {
+				t.Errorf("got %d messages, want %d", lines, tt.stdout)
+			}
+			for _, want := range tt.stderr {
+				if !strings.Contains(stderr, want) {
+					t.Errorf("stderr %q doesn't contain %q", stderr, want)
+				}
+			}
+			if ids := api.JobIds(); len(ids) != tt.live {
+				t.Errorf("live search jobs %v, want %d", ids, tt.live)
+			}
+		})
+	}
+}
+
+func TestJobProcessFullInterrupt(t *testing.T) {
+	for _, keep := range []bool{false, true} {
+		t.Run(fmt.Sprintf("keep-on-interrupt=%t", keep), func(t *testing.T) {
+			api := newFakeAPI(t, fakeserver.Fixture{Scenarios: []fakeserver.Scenario{
+				{States: []fakeserver.Step{{State: "GATHERING RESULTS"}}},
+			}})
+			args := []string{"jobProcessFull", "-q", "error", "-d", "1h", "--poll-interval", "10ms", "--poll-max-interval", "10ms", "--stall-polls", "0"}
+			if keep {
+				args = append(args, "--keep-on-interrupt")
+			}
+			run := startCLI(t, t.TempDir(), api.args(args...)...)
+			deadline := time.Now().Add(10 * time.Second)
+			for !strings.Contains(run.stderr.String(), "Status:") {
+				if time.Now().After(deadline) {
+					t.Fatalf("the job was never polled: %s", run.stderr.String())
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+			if err := run.cmd.Process.Signal(os.Interrupt); err != nil {
+				t.Fatal(err)
+			}
+			if code := run.wait(t); code != ExitInterrupted {
+				t.Errorf("exit code %d, want %d", code, ExitInterrupted)
+			}
+			if !strings.Contains(run.stderr.String(), "Interrupted") {
+				t.Errorf("stderr %q doesn't report the interruption", run.stderr.String())
+			}
+			if ids := api.JobIds(); keep != (len(ids) == 1) {
+				t.Errorf("live search jobs %v with keep-on-interrupt=%t", ids, keep)
+			}
+		})
+	}
+}
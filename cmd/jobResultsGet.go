@@ -1,12 +1,11 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
-	"github.com/nhoag/sumo-search-job-cli/client"
 	"github.com/spf13/cobra"
 )
 
@@ -14,6 +13,7 @@ var (
 	LimitOpt        int32
 	OffsetOpt       int32
 	SleepSecondsOpt int32
+	OutputOpt       string
 )
 
 // jobResultsGetCmd represents the jobResultsGet command
@@ -22,13 +22,17 @@ var jobResultsGetCmd = &cobra.Command{
 	Short: "Fetch the results for a Sumo Logic Search Job",
 	Long: `The jobResultsGet command will fetch the results for a Sumo Logic
 	Search Job via the Search Job API.`,
+	Args:   cobra.ExactArgs(1),
+	PreRun: preparePolling,
 	Run: func(cmd *cobra.Command, args []string) {
 		QuietOpt, _ = cmd.Flags().GetBool("quiet")
 		VerboseOpt, _ = cmd.Flags().GetBool("verbose")
 		if VerboseOpt {
 			fmt.Fprintf(os.Stderr, "%d\tSTART\tjobResultsGet\n", time.Now().UnixNano())
 		}
-		executeJobResults(cmd, args)
+		validateStatusCheck()
+		validateJobResults()
+		exitOnError(executeJobResults(cmd, args))
 		if VerboseOpt {
 			fmt.Fprintf(os.Stderr, "%d\tEND\tjobResultsGet\n", time.Now().UnixNano())
 		}
@@ -39,68 +43,104 @@ func validateJobResults() {
 	if VerboseOpt {
 		fmt.Fprintf(os.Stderr, "%d\tSTART\tjobResultsGet::validateJobResults()\n", time.Now().UnixNano())
 	}
-	// validation logic goes here
+	cobra.CheckErr(validateOutputFormat(OutputOpt))
+	validateStream()
+	validatePaging()
+	validateCheckpoint()
+	validateKeepAliveInterval()
 	if VerboseOpt {
 		fmt.Fprintf(os.Stderr, "%d\tEND\tjobResultsGet::validateJobResults()\n", time.Now().UnixNano())
 	}
 }
 
-func executeJobResults(cmd *cobra.Command, args []string) {
+func executeJobResults(cmd *cobra.Command, args []string) error {
 	if VerboseOpt {
 		fmt.Fprintf(os.Stderr, "%d\tSTART\tjobResultsGet::executeJobResults()\n", time.Now().UnixNano())
 	}
-	status := executeStatusCheck(cmd, args)
+	jobId := args[0]
+	// Requests for results alone may be further apart than the job's expiry
+	// when they are paced or the output is consumed slowly.
+	keepAlive := startKeepAlive(cmd.Context(), jobId)
+	err := fetchJobResults(cmd, jobId)
+	if expired := keepAlive.stop(); expired != nil {
+		return expired
+	}
+	if err != nil {
+		return err
+	}
+	if VerboseOpt {
+		fmt.Fprintf(os.Stderr, "%d\tEND\tjobResultsGet::executeJobResults()\n", time.Now().UnixNano())
+	}
+	return nil
+}
 
-	if len(args) == 0 {
-		fmt.Fprintf(os.Stderr, "No jobId specified!")
-		os.Exit(1)
+// fetchJobResults waits for a search job and writes its results.
+func fetchJobResults(cmd *cobra.Command, jobId string) error {
+	if StreamOpt {
+		return executeStreamResults(cmd, jobId)
 	}
-	jobId := args[0]
+	if len(CheckpointOpt) > 0 {
+		return executeCheckpointedResults(cmd, jobId)
+	}
+	status, err := pollStatus(cmd, jobId, true)
+	if err != nil {
+		return err
+	}
+
 	all, _ := cmd.Flags().GetBool("all")
 	messagesOnly, _ := cmd.Flags().GetBool("messages")
 	recordsOnly, _ := cmd.Flags().GetBool("records")
-	msgOffset := OffsetOpt
-	recOffset := OffsetOpt
-	if !recordsOnly && *status.MessageCount > int32(0) {
-		for {
-			messages := client.GetSearchJobMessages(jobId, LimitOpt, msgOffset)
-			messagesJson, _ := json.MarshalIndent(messages, "", "    ")
-			fmt.Println(string(messagesJson))
-			msgOffset = msgOffset + LimitOpt
-			if !all || msgOffset > *status.MessageCount {
-				break
+	out := os.Stdout
+	if len(OutputFileOpt) > 0 {
+		file, err := os.Create(OutputFileOpt)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	writer, err := newResultWriter(out, OutputOpt)
+	if err != nil {
+		return err
+	}
+	if !messagesOnly && !recordsOnly {
+		writer.keyed("messages", "records")
+	}
+	if !recordsOnly {
+		if status.GetMessageCount() > 0 {
+			if _, err := fetchPages(cmd.Context(), messagePages(jobId), OffsetOpt, status.GetMessageCount(), all, writer.WritePage); err != nil {
+				return err
 			}
-			time.Sleep(time.Duration(SleepSecondsOpt) * time.Second)
+		}
+		if err := writer.EndSection(); err != nil {
+			return err
 		}
 	}
-	if !messagesOnly && *status.RecordCount > int32(0) {
-		for {
-			records := client.GetSearchJobRecords(jobId, LimitOpt, recOffset)
-			recordsJson, _ := json.MarshalIndent(records, "", "    ")
-			fmt.Println(string(recordsJson))
-			recOffset = recOffset + LimitOpt
-			if !all || recOffset > *status.RecordCount {
-				break
+	if !messagesOnly {
+		if status.GetRecordCount() > 0 {
+			if _, err := fetchPages(cmd.Context(), recordPages(jobId), OffsetOpt, status.GetRecordCount(), all, writer.WritePage); err != nil {
+				return err
 			}
-			time.Sleep(time.Duration(SleepSecondsOpt) * time.Second)
+		}
+		if err := writer.EndSection(); err != nil {
+			return err
 		}
 	}
 	if !QuietOpt && *status.MessageCount == int32(0) && *status.RecordCount == int32(0) {
 		fmt.Fprintf(os.Stderr, "No results for the specified search\n")
 	}
-	if VerboseOpt {
-		fmt.Fprintf(os.Stderr, "%d\tEND\tjobResultsGet::executeJobResults()\n", time.Now().UnixNano())
-	}
+	return nil
 }
 
 func init() {
 	rootCmd.AddCommand(jobResultsGetCmd)
+	addKeepAliveFlags(jobResultsGetCmd.Flags())
 	jobResultsGetCmd.Flags().BoolP("records", "r", false, "Retrieve records only")
 	jobResultsGetCmd.Flags().BoolP("messages", "m", false, "Retrieve messages only")
 	jobResultsGetCmd.Flags().BoolP("all", "a", false, "Retrieve all paginated results (default is first page)")
-	jobResultsGetCmd.Flags().Int32VarP(&LimitOpt, "limit", "l", 100, "Specify pagination limit")
+	jobResultsGetCmd.Flags().Int32VarP(&LimitOpt, "limit", "l", 0, "Specify pagination limit, up to 10000 (default chooses the page size)")
 	jobResultsGetCmd.Flags().Int32VarP(&OffsetOpt, "offset", "o", 0, "Specify pagination offset")
-	jobResultsGetCmd.Flags().Int32VarP(&SleepSecondsOpt, "sleep", "Z", 1, "Specify sleep seconds")
+	jobResultsGetCmd.Flags().Int32VarP(&SleepSecondsOpt, "sleep", "Z", 1, "Fixed seconds between status polls, instead of backing off, and between result pages, which are then fetched one at a time")
 	jobResultsGetCmd.Flags().BoolP("poll", "p", true, "Poll for status until search job is complete")
-	// @todo: Add format options
+	jobResultsGetCmd.Flags().StringVarP(&OutputOpt, "output", "O", "json", "Output format ("+strings.Join(OutputFormats, ", ")+")")
 }
@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nhoag/sumo-search-job-cli/fakeserver"
+)
+
+func TestJobResultsGet(t *testing.T) {
+	scenario := messageScenario("", 150)
+	scenario.RecordFields = []fakeserver.Field{{Name: "_count"}, {Name: "host"}}
+	scenario.Records = []map[string]string{{"_count": "3", "host": "a"}, {"_count": "5", "host": "b"}}
+	api := newFakeAPI(t, fakeserver.Fixture{Scenarios: []fakeserver.Scenario{scenario}})
+	var messages []string
+	for i := 0; i < 150; i++ {
+		messages = append(messages, fmt.Sprintf("%d,m%d", i, i))
+	}
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"all messages", []string{"-a", "-m", "-l", "40"}, append([]string{"_messageid,_raw"}, messages...)},
+		{"first page", []string{"-m", "-l", "40"}, append([]string{"_messageid,_raw"}, messages[:40]...)},
+		{"offset", []string{"-a", "-m", "-l", "40", "-o", "120"}, append([]string{"_messageid,_raw"}, messages[120:]...)},
+		{"records", []string{"-a", "-r"}, []string{"_count,host", "3,a", "5,b"}},
+	}
+	jobId := api.createJob(t, "error")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"jobResultsGet", jobId, "-O", "csv", "--poll-interval", "1ms"}, tt.args...)
+			stdout, stderr, code := runCLI(t, api.args(args...)...)
+			if code != 0 {
+				t.Fatalf("exit code %d: %s", code, stderr)
+			}
+			if got, want := strings.TrimSpace(stdout), strings.Join(tt.want, "\n"); got != want {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestJobResultsGetJSON(t *testing.T) {
+	scenario := messageScenario("", 150)
+	scenario.RecordFields = []fakeserver.Field{{Name: "_count"}, {Name: "host"}}
+	scenario.Records = []map[string]string{{"_count": "3", "host": "a"}, {"_count": "5", "host": "b"}}
+	api := newFakeAPI(t, fakeserver.Fixture{Scenarios: []fakeserver.Scenario{messageScenario("quiet", 0), scenario}})
+	jobId := api.createJob(t, "error")
+	quietJobId := api.createJob(t, "quiet")
+	tests := []struct {
+		name string
+		args []string
+		// file is where the output goes, if not stdout.
+		file string
+		// messages and records are the lengths of the arrays of a keyed
+		// document, or -1 for a section that's not in the document.
+		messages int
+		records  int
+		// array is the length of the single array written with -m or -r.
+		array int
+	}{
+		{name: "messages and records", args: []string{"jobResultsGet", jobId, "-a", "-l", "40"}, messages: 150, records: 2},
+		{name: "messages only", args: []string{"jobResultsGet", jobId, "-a", "-m"}, messages: -1, records: -1, array: 150},
+		{name: "records only", args: []string{"jobResultsGet", jobId, "-a", "-r"}, messages: -1, records: -1, array: 2},
+		{name: "no results", args: []string{"jobResultsGet", quietJobId, "-a"}, messages: 0, records: 0},
+		{name: "stream", args: []string{"jobResultsGet", jobId, "--stream"}, messages: 150, records: 2},
+		{name: "checkpoint", args: []string{"jobResultsGet", jobId, "-a", "--checkpoint", "DIR/results.ckpt"}, file: "results.json", messages: 150, records: 2},
+		{name: "jobProcessFull", args: []string{"jobProcessFull", "-q", "error", "-d", "1h"}, messages: 150, records: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var args []string
+			for _, arg := range tt.args {
+				args = append(args, strings.ReplaceAll(arg, "DIR", dir))
+			}
+			args = append(args, "-O", "json", "--poll-interval", "1ms")
+			if len(tt.file) > 0 {
+				args = append(args, "--output-file", filepath.Join(dir, tt.file))
+			}
+			stdout, stderr, code := runCLI(t, api.args(args...)...)
+			if code != 0 {
+				t.Fatalf("exit code %d: %s", code, stderr)
+			}
+			output := []byte(stdout)
+			if len(tt.file) > 0 {
+				content, err := os.ReadFile(filepath.Join(dir, tt.file))
+				if err != nil {
+					t.Fatal(err)
+				}
+				output = content
+			}
+			// The output is a single JSON document.
+			decoder := json.NewDecoder(bytes.NewReader(output))
+			decoder.DisallowUnknownFields()
+			if tt.messages < 0 {
+				var rows []map[string]string
+				if err := decoder.Decode(&rows); err != nil {
+					t.Fatalf("%v in\n%s", err, output)
+				}
+				if len(rows) != tt.array {
+					t.Errorf("got %d rows, want %d", len(rows), tt.array)
+				}
+			} else {
+				var document struct {
+					Messages *[]map[string]string `json:"messages"`
+					Records  *[]map[string]string `json:"records"`
+				}
+				if err := decoder.Decode(&document); err != nil {
+					t.Fatalf("%v in\n%s", err, output)
+				}
+				if document.Messages == nil || len(*document.Messages) != tt.messages {
+					t.Errorf("messages %v, want %d", document.Messages, tt.messages)
+				}
+				if document.Records == nil || len(*document.Records) != tt.records {
+					t.Errorf("records %v, want %d", document.Records, tt.records)
+				}
+			}
+			if decoder.More() {
+				t.Errorf("more than one JSON document in\n%s", output)
+			}
+		})
+	}
+}
+
+func TestJobResultsGetErrors(t *testing.T) {
+	fixture := fakeserver.Fixture{Scenarios: []fakeserver.Scenario{
+		{Match: "cancel", States: []fakeserver.Step{{State: "GATHERING RESULTS"}, {State: "CANCELLED"}}},
+		{Match: "fail", States: []fakeserver.Step{{State: "DONE GATHERING RESULTS"}}, PendingErrors: []string{"Parse error: bad query"}},
+		messageScenario("", 50),
+	}}
+	tests := []struct {
+		name   string
+		query  string
+		faults []fakeserver.Fault
+		code   int
+		stdout int
+		stderr string
+	}{
+		{name: "rate limited pages", query: "error", faults: []fakeserver.Fault{{Op: "messages", Status: 429, Count: 2}}, stdout: 50, stderr: "retrying"},
+		{name: "server error", query: "error", faults: []fakeserver.Fault{{Op: "messages", Status: 500}}, code: 1, stderr: "Error: GetSearchJobMessages: HTTP 500"},
+		{name: "cancelled", query: "cancel", code: 1, stderr: "was cancelled"},
+		{name: "job errors", query: "fail", code: 1, stderr: "failed: Parse error: bad query"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakeAPI(t, fixture)
+			jobId := api.createJob(t, tt.query)
+			for _, fault := range tt.faults {
+				api.AddFault(fault)
+			}
+			stdout, stderr, code := runCLI(t, api.args("jobResultsGet", jobId, "-a", "-m", "-O", "ndjson", "--poll-interval", "1ms", "--retry-max-attempts", "3")...)
+			if code != tt.code {
+				t.Errorf("exit code %d, want %d", code, tt.code)
+			}
+			if lines := strings.Count(stdout, "\n"); lines != tt.stdout {
+				t.Errorf("got %d messages, want %d", lines, tt.stdout)
+			}
+			if !strings.Contains(stderr, tt.stderr) {
+				t.Errorf("stderr %q doesn't contain %q", stderr, tt.stderr)
+			}
+		})
+	}
+}
+
+func TestJobResultsGetSleep(t *testing.T) {
+	api := newFakeAPI(t, fakeserver.Fixture{Scenarios: []fakeserver.Scenario{messageScenario("", 30)}})
+	jobId := api.createJob(t, "error")
+	// --sleep fetches the pages one at a time, a second apart.
+	start := time.Now()
+	stdout, stderr, code := runCLI(t, api.args("jobResultsGet", jobId, "-a", "-m", "-l", "10", "-O", "ndjson", "--sleep", "1", "--poll-interval", "1ms", "--poll-max-interval", "1ms")...)
+	if code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	if lines := strings.Count(stdout, "\n"); lines != 30 {
+		t.Errorf("got %d messages, want 30", lines)
+	}
+	if elapsed := time.Since(start); elapsed < 2*time.Second {
+		t.Errorf("3 pages took %s, want at least 2s", elapsed)
+	}
+
+	_, stderr, code = runCLI(t, api.args("jobResultsGet", jobId, "-a", "--sleep", "1", "--parallel", "4")...)
+	if code != 1 || !strings.Contains(stderr, "sleep fetches result pages one at a time and can't be combined with parallel 4") {
+		t.Errorf("exit code %d with %q, want a conflict error", code, stderr)
+	}
+}
@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	openapi "github.com/nhoag/sumologic-search-job-client-go"
+
+	"github.com/nhoag/sumo-search-job-cli/client"
+	"github.com/nhoag/sumo-search-job-cli/timeexpr"
+)
+
+const (
+	// jobTimeLayout is the time format used in search job definitions.
+	jobTimeLayout = timeexpr.Layout
+	// maxJobMessages is the number of messages a non-aggregate search job
+	// returns before it is FORCE PAUSED.
+	maxJobMessages = 100000
+	// minSplitWindow is the smallest window bisection will produce.
+	minSplitWindow = time.Second
+)
+
+var (
+	SplitOpt            string
+	SplitConcurrencyOpt int
+)
+
+// timeWindow is a sub-window of a split search.
+type timeWindow struct {
+	From time.Time
+	To   time.Time
+}
+
+// windowResult holds the messages of a finished sub-window.
+type windowResult struct {
+	fields []client.Field
+	rows   []client.Row
+	err    error
+}
+
+func validateSplit(cmd *cobra.Command) {
+	if len(SplitOpt) == 0 {
+		return
+	}
+	// Aggregates can't be merged across windows.
+	if recordsOnly, _ := cmd.Flags().GetBool("records"); recordsOnly {
+		cobra.CheckErr(fmt.Errorf("split searches only return messages; --records can't be combined with --split"))
+	}
+	if SplitOpt != "auto" {
+		size, err := timeexpr.ParseDuration(SplitOpt)
+		if err != nil || size < minSplitWindow {
+			cobra.CheckErr(fmt.Errorf("invalid split %q: expected auto or a duration of at least %s", SplitOpt, minSplitWindow))
+		}
+	}
+	if SplitConcurrencyOpt < 1 {
+		cobra.CheckErr(fmt.Errorf("split-concurrency must be at least 1"))
+	}
+}
+
+// parseJobTime parses a job definition time in the given location.
+func parseJobTime(value string, location *time.Location) (time.Time, error) {
+	t, err := time.ParseInLocation(jobTimeLayout, value, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to parse time %q", value)
+	}
+	return t, nil
+}
+
+// splitWindows divides [from, to) into consecutive windows of at most size.
+// A zero size yields the whole range as a single window.
+func splitWindows(from time.Time, to time.Time, size time.Duration) []timeWindow {
+	if size <= 0 {
+		return []timeWindow{{From: from, To: to}}
+	}
+	var windows []timeWindow
+	for start := from; start.Before(to); start = start.Add(size) {
+		end := start.Add(size)
+		if end.After(to) {
+			end = to
+		}
+		windows = append(windows, timeWindow{From: start, To: end})
+	}
+	return windows
+}
+
+// messageTime returns a row's _messagetime in epoch milliseconds.
+func messageTime(row client.Row) int64 {
+	value, _ := strconv.ParseInt(row["_messagetime"], 10, 64)
+	return value
+}
+
+// executeSplitProcess runs the search as one job per sub-window, bisecting
+// windows that hit the message limit, and writes the merged messages in
+// _messagetime order.
+func executeSplitProcess(cmd *cobra.Command, jobDef JobDefinition) error {
+	if VerboseOpt {
+		fmt.Fprintf(os.Stderr, "%d\tSTART\tjobProcessFull::executeSplitProcess()\n", time.Now().UnixNano())
+	}
+	location, err := time.LoadLocation(jobDef.Timezone)
+	if err != nil {
+		return err
+	}
+	from, err := parseJobTime(jobDef.From, location)
+	if err != nil {
+		return err
+	}
+	to, err := parseJobTime(jobDef.To, location)
+	if err != nil {
+		return err
+	}
+	var size time.Duration
+	if SplitOpt != "auto" {
+		size, _ = timeexpr.ParseDuration(SplitOpt)
+	}
+
+	writer, err := newResultWriter(os.Stdout, OutputOpt)
+	if err != nil {
+		return err
+	}
+	// slots bounds the live jobs, including those of bisected halves.
+	slots := make(chan struct{}, SplitConcurrencyOpt)
+	windows := splitWindows(from.In(location), to.In(location), size)
+	// Windows are disjoint and in order, so writing them one after another
+	// keeps the overall output ordered. Each window's messages are released
+	// once written, and only a few windows finish ahead of the one being
+	// written.
+	total := 0
+	err = runOrdered(cmd.Context(), len(windows), SplitConcurrencyOpt, func(ctx context.Context, i int) (windowResult, error) {
+		fields, rows, err := runWindow(ctx, jobDef, windows[i], slots)
+		return windowResult{fields: fields, rows: rows}, err
+	}, func(i int, r windowResult) error {
+		total += len(r.rows)
+		return writer.WritePage(r.fields, r.rows)
+	})
+	if err != nil {
+		return err
+	}
+	if err := writer.EndSection(); err != nil {
+		return err
+	}
+	if !QuietOpt && total == 0 {
+		fmt.Fprintf(os.Stderr, "No results for the specified search\n")
+	}
+	if VerboseOpt {
+		fmt.Fprintf(os.Stderr, "%d\tEND\tjobProcessFull::executeSplitProcess()\n", time.Now().UnixNano())
+	}
+	return nil
+}
+
+// runWindow returns the messages of one window sorted by _messagetime. When
+// the window's job hits the message limit, the window is bisected and both
+// halves are searched concurrently. slots bounds the number of live jobs.
+func runWindow(ctx context.Context, jobDef JobDefinition, window timeWindow, slots chan struct{}) ([]client.Field, []client.Row, error) {
+	span := window.To.Sub(window.From)
+	canSplit := span >= 2*minSplitWindow
+	fields, rows, limited, err := runWindowJob(ctx, jobDef, window, slots, canSplit)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !limited || !canSplit {
+		if limited && !QuietOpt {
+			fmt.Fprintf(os.Stderr, "Window %s - %s hit the message limit and cannot be split further; results are truncated\n",
+				window.From.Format(jobTimeLayout), window.To.Format(jobTimeLayout))
+		}
+		sort.SliceStable(rows, func(i, j int) bool {
+			return messageTime(rows[i]) < messageTime(rows[j])
+		})
+		return fields, rows, nil
+	}
+
+	middle := window.From.Add((span / 2).Truncate(time.Second))
+	if !QuietOpt {
+		fmt.Fprintf(os.Stderr, "Window %s - %s hit the message limit; splitting at %s\n",
+			window.From.Format(jobTimeLayout), window.To.Format(jobTimeLayout), middle.Format(jobTimeLayout))
+	}
+	halves := []timeWindow{{From: window.From, To: middle}, {From: middle, To: window.To}}
+	var results [2]windowResult
+	var wg sync.WaitGroup
+	for i, half := range halves {
+		wg.Add(1)
+		go func(i int, half timeWindow) {
+			defer wg.Done()
+			f, r, err := runWindow(ctx, jobDef, half, slots)
+			results[i] = windowResult{fields: f, rows: r, err: err}
+		}(i, half)
+	}
+	wg.Wait()
+	for _, result := range results {
+		if result.err != nil {
+			return nil, nil, result.err
+		}
+	}
+	fields = results[0].fields
+	if len(fields) == 0 {
+		fields = results[1].fields
+	}
+	return fields, append(results[0].rows, results[1].rows...), nil
+}
+
+// runWindowJob runs a search job for window and returns its messages. It
+// reports whether the job hit the message limit; in that case messages are
+// only fetched when the window cannot be split.
+func runWindowJob(ctx context.Context, jobDef JobDefinition, window timeWindow, slots chan struct{}, canSplit bool) ([]client.Field, []client.Row, bool, error) {
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, nil, false, ctx.Err()
+	}
+	defer func() { <-slots }()
+
+	jobDef.From = window.From.Format(jobTimeLayout)
+	jobDef.To = window.To.Format(jobTimeLayout)
+	var fields []client.Field
+	var rows []client.Row
+	limited := false
+	err := runJob(ctx, jobDef, func(status *openapi.SearchJobState) (bool, error) {
+		if status.GetRecordCount() > 0 {
+			return false, fmt.Errorf("window %s - %s returned aggregate records, which split searches can't merge; run the search without --split", jobDef.From, jobDef.To)
+		}
+		limited = status.GetState() == "FORCE PAUSED" || status.GetMessageCount() >= maxJobMessages
+		return limited && canSplit, nil
+	}, func(jobId string, status *openapi.SearchJobState) error {
+		if limited && canSplit {
+			return nil
+		}
+		var err error
+		fields, rows, err = collectPages(ctx, messagePages(jobId), status.GetMessageCount())
+		return err
+	})
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return fields, rows, limited, nil
+}
+
+func init() {
+	for _, flags := range processFullFlagSets() {
+		flags.StringVar(&SplitOpt, "split", "", "Split the search window into sub-window jobs: auto, or a window size such as 1h or 1d")
+		flags.IntVar(&SplitConcurrencyOpt, "split-concurrency", 4, "Maximum number of concurrent sub-window jobs")
+	}
+}
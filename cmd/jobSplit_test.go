@@ -0,0 +1,418 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nhoag/sumo-search-job-cli/client"
+	"github.com/nhoag/sumo-search-job-cli/fakeserver"
+)
+
+// useFakeAPI points the in-process client at a fake server for fixture, with
+// fast polling and no rate limit or retries, until the test ends.
+func useFakeAPI(t *testing.T, fixture fakeserver.Fixture) *fakeserver.Server {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+
+	server := fakeserver.New(fakeserver.Config{Fixture: fixture})
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+	c, err := client.New(client.Config{
+		Endpoint:  srv.URL + "/api",
+		RateLimit: &client.RateLimit{},
+		Retry:     &client.RetryPolicy{MaxAttempts: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved, interval, maxInterval, quiet := apiClient, PollIntervalOpt, PollMaxIntervalOpt, QuietOpt
+	t.Cleanup(func() {
+		apiClient, PollIntervalOpt, PollMaxIntervalOpt, QuietOpt = saved, interval, maxInterval, quiet
+	})
+	apiClient, PollIntervalOpt, PollMaxIntervalOpt, QuietOpt = c, time.Millisecond, time.Millisecond, true
+	return server
+}
+
+// captureStderr returns what f writes to os.Stderr.
+func captureStderr(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	done := make(chan string)
+	go func() {
+		content, _ := io.ReadAll(r)
+		done <- string(content)
+	}()
+	defer func() {
+		os.Stderr = stderr
+	}()
+	f()
+	w.Close()
+	return <-done
+}
+
+// timedScenario returns a split scenario with a message at each of the
+// given offsets, in milliseconds from the epoch, that is FORCE PAUSED above
+// limit messages.
+func timedScenario(limit int, offsets ...int64) fakeserver.Scenario {
+	scenario := fakeserver.Scenario{
+		ByTime:       true,
+		MessageLimit: limit,
+		Fields:       []fakeserver.Field{{Name: "_messagetime"}, {Name: "_raw"}},
+	}
+	for i, offset := range offsets {
+		scenario.Messages = append(scenario.Messages, map[string]string{
+			"_messagetime": fmt.Sprint(offset),
+			"_raw":         fmt.Sprintf("m%d", i),
+		})
+	}
+	return scenario
+}
+
+// spread returns n offsets from start, step milliseconds apart.
+func spread(start int64, step int64, n int) []int64 {
+	offsets := make([]int64, n)
+	for i := range offsets {
+		offsets[i] = start + int64(i)*step
+	}
+	return offsets
+}
+
+// messageTimes returns the _messagetime of every row.
+func messageTimes(rows []client.Row) []int64 {
+	times := make([]int64, len(rows))
+	for i, row := range rows {
+		times[i] = messageTime(row)
+	}
+	return times
+}
+
+func epochWindow(from time.Duration, to time.Duration) timeWindow {
+	epoch := time.Unix(0, 0).UTC()
+	return timeWindow{From: epoch.Add(from), To: epoch.Add(to)}
+}
+
+func TestSplitWindows(t *testing.T) {
+	at := func(hours int) time.Time {
+		return time.Date(2024, 1, 1, hours, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		from time.Time
+		to   time.Time
+		size time.Duration
+		want []timeWindow
+	}{
+		{name: "whole range", from: at(0), to: at(3), want: []timeWindow{{at(0), at(3)}}},
+		{name: "even", from: at(0), to: at(3), size: time.Hour, want: []timeWindow{{at(0), at(1)}, {at(1), at(2)}, {at(2), at(3)}}},
+		{name: "last window shortened", from: at(0), to: at(5), size: 2 * time.Hour, want: []timeWindow{{at(0), at(2)}, {at(2), at(4)}, {at(4), at(5)}}},
+		{name: "size beyond range", from: at(0), to: at(1), size: 24 * time.Hour, want: []timeWindow{{at(0), at(1)}}},
+		{name: "empty range", from: at(1), to: at(1), size: time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitWindows(tt.from, tt.to, tt.size); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitWindows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunOrdered(t *testing.T) {
+	const n, parallel = 20, 3
+	var mu sync.Mutex
+	running, maxRunning, started, written, maxAhead := 0, 0, 0, 0, 0
+	var order []int
+	err := runOrdered(context.Background(), n, parallel, func(ctx context.Context, i int) (int, error) {
+		mu.Lock()
+		running++
+		started++
+		maxRunning = max(maxRunning, running)
+		maxAhead = max(maxAhead, started-written)
+		mu.Unlock()
+		// Later items finish first, so results must be held back until
+		// the earlier ones are written.
+		time.Sleep(time.Duration(n-i) * 100 * time.Microsecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return i * i, nil
+	}, func(i int, result int) error {
+		if result != i*i {
+			t.Errorf("item %d: result %d, want %d", i, result, i*i)
+		}
+		mu.Lock()
+		written++
+		mu.Unlock()
+		order = append(order, i)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, got := range order {
+		if got != i {
+			t.Fatalf("write order %v is not sequential", order)
+		}
+	}
+	if len(order) != n {
+		t.Errorf("wrote %d items, want %d", len(order), n)
+	}
+	if maxRunning > parallel {
+		t.Errorf("%d items ran at once, want at most %d", maxRunning, parallel)
+	}
+	if maxAhead > 2*parallel {
+		t.Errorf("%d items started ahead of the one being written, want at most %d", maxAhead, 2*parallel)
+	}
+}
+
+func TestRunOrderedErrors(t *testing.T) {
+	failure := errors.New("failure")
+	tests := []struct {
+		name    string
+		run     func(ctx context.Context, i int) (int, error)
+		write   func(i int, result int) error
+		written int
+	}{
+		{
+			name: "run error",
+			run: func(ctx context.Context, i int) (int, error) {
+				if i == 3 {
+					return 0, failure
+				}
+				return i, nil
+			},
+			written: 3,
+		},
+		{
+			name: "write error",
+			run:  func(ctx context.Context, i int) (int, error) { return i, nil },
+			write: func(i int, result int) error {
+				if i == 2 {
+					return failure
+				}
+				return nil
+			},
+			written: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			written := 0
+			err := runOrdered(context.Background(), 10, 2, tt.run, func(i int, result int) error {
+				if tt.write != nil {
+					if err := tt.write(i, result); err != nil {
+						return err
+					}
+				}
+				written++
+				return nil
+			})
+			if !errors.Is(err, failure) {
+				t.Fatalf("runOrdered() error = %v, want %v", err, failure)
+			}
+			if written != tt.written {
+				t.Errorf("wrote %d items before the error, want %d", written, tt.written)
+			}
+		})
+	}
+}
+
+func TestRunWindow(t *testing.T) {
+	jobDef := JobDefinition{Query: "error", Timezone: "UTC"}
+	tests := []struct {
+		name    string
+		limit   int
+		offsets []int64
+		window  timeWindow
+		want    []int64
+		creates int
+		// messages is the number of jobs whose messages were fetched.
+		messages int
+		stderr   []string
+	}{
+		{
+			name:     "under the limit",
+			limit:    10,
+			offsets:  []int64{2500, 500, 3500, 1500},
+			window:   epochWindow(0, 4*time.Second),
+			want:     []int64{500, 1500, 2500, 3500},
+			creates:  1,
+			messages: 1,
+		},
+		{
+			// The paused parent job isn't fetched; each half runs a job of
+			// its own and the halves are merged in time order.
+			name:     "bisected",
+			limit:    10,
+			offsets:  append(spread(2500, 10, 8), spread(500, 10, 8)...),
+			window:   epochWindow(0, 4*time.Second),
+			want:     append(spread(500, 10, 8), spread(2500, 10, 8)...),
+			creates:  3,
+			messages: 2,
+			stderr:   []string{"Window 1970-01-01T00:00:00 - 1970-01-01T00:00:04 hit the message limit; splitting at 1970-01-01T00:00:02"},
+		},
+		{
+			// Both halves of the first split are over the limit again.
+			name:     "bisected twice",
+			limit:    5,
+			offsets:  spread(0, 500, 16),
+			window:   epochWindow(0, 8*time.Second),
+			want:     spread(0, 500, 16),
+			creates:  7,
+			messages: 4,
+			stderr: []string{
+				"hit the message limit; splitting at 1970-01-01T00:00:04",
+				"hit the message limit; splitting at 1970-01-01T00:00:02",
+				"hit the message limit; splitting at 1970-01-01T00:00:06",
+			},
+		},
+		{
+			// A window shorter than two seconds can't be split into whole
+			// seconds, so the paused job's messages are returned.
+			name:     "truncated",
+			limit:    10,
+			offsets:  spread(100, 10, 12),
+			window:   epochWindow(0, time.Second),
+			want:     spread(100, 10, 10),
+			creates:  1,
+			messages: 1,
+			stderr:   []string{"Window 1970-01-01T00:00:00 - 1970-01-01T00:00:01 hit the message limit and cannot be split further; results are truncated"},
+		},
+		{
+			// The first half is split down to the one second floor, where
+			// it is truncated; the second half is complete.
+			name:     "truncated after bisection",
+			limit:    10,
+			offsets:  append(spread(100, 10, 12), spread(2500, 10, 3)...),
+			window:   epochWindow(0, 3*time.Second),
+			want:     append(spread(100, 10, 10), spread(2500, 10, 3)...),
+			creates:  3,
+			messages: 2,
+			stderr: []string{
+				"Window 1970-01-01T00:00:00 - 1970-01-01T00:00:03 hit the message limit; splitting at 1970-01-01T00:00:01",
+				"Window 1970-01-01T00:00:00 - 1970-01-01T00:00:01 hit the message limit and cannot be split further; results are truncated",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := useFakeAPI(t, fakeserver.Fixture{Scenarios: []fakeserver.Scenario{timedScenario(tt.limit, tt.offsets...)}})
+			QuietOpt = false
+			var rows []client.Row
+			var err error
+			stderr := captureStderr(t, func() {
+				_, rows, err = runWindow(context.Background(), jobDef, tt.window, make(chan struct{}, 4))
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := messageTimes(rows); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("message times %v, want %v", got, tt.want)
+			}
+			if got := server.Requests("create"); got != tt.creates {
+				t.Errorf("created %d search jobs, want %d", got, tt.creates)
+			}
+			if got := server.Requests("messages"); got != tt.messages {
+				t.Errorf("fetched messages %d times, want %d", got, tt.messages)
+			}
+			for _, want := range tt.stderr {
+				if !strings.Contains(stderr, want) {
+					t.Errorf("stderr %q doesn't contain %q", stderr, want)
+				}
+			}
+			if ids := server.JobIds(); len(ids) != 0 {
+				t.Errorf("search jobs %v were not deleted", ids)
+			}
+		})
+	}
+}
+
+// TestRunWindowReleasesSlot checks that a window gives up its slot before
+// its halves wait for theirs, so that bisection can't deadlock with a
+// single slot.
+func TestRunWindowReleasesSlot(t *testing.T) {
+	useFakeAPI(t, fakeserver.Fixture{Scenarios: []fakeserver.Scenario{timedScenario(5, spread(0, 500, 16)...)}})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	slots := make(chan struct{}, 1)
+	_, rows, err := runWindow(ctx, JobDefinition{Query: "error", Timezone: "UTC"}, epochWindow(0, 8*time.Second), slots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 16 {
+		t.Errorf("got %d messages, want 16", len(rows))
+	}
+	if len(slots) != 0 {
+		t.Errorf("%d slots still held", len(slots))
+	}
+}
+
+func TestRunWindowAggregate(t *testing.T) {
+	scenario := timedScenario(0, 500)
+	scenario.RecordFields = []fakeserver.Field{{Name: "_count"}}
+	scenario.Records = []map[string]string{{"_count": "1"}}
+	server := useFakeAPI(t, fakeserver.Fixture{Scenarios: []fakeserver.Scenario{scenario}})
+	_, _, err := runWindow(context.Background(), JobDefinition{Query: "error", Timezone: "UTC"}, epochWindow(0, time.Second), make(chan struct{}, 1))
+	if err == nil || !strings.Contains(err.Error(), "returned aggregate records") {
+		t.Fatalf("runWindow() error = %v, want aggregate records error", err)
+	}
+	if ids := server.JobIds(); len(ids) != 0 {
+		t.Errorf("search jobs %v were not deleted", ids)
+	}
+}
+
+func TestJobProcessFullSplit(t *testing.T) {
+	// Four hours of messages, 40 per hour, with a limit of 50 per job: the
+	// two hour windows are bisected once.
+	var offsets []int64
+	for hour := int64(3); hour >= 0; hour-- {
+		offsets = append(offsets, spread(hour*3600000, 60000, 40)...)
+	}
+	api := newFakeAPI(t, fakeserver.Fixture{Scenarios: []fakeserver.Scenario{timedScenario(50, offsets...)}})
+	stdout, stderr, code := runCLI(t, api.args("jobProcessFull", "-q", "error",
+		"-f", "1970-01-01T00:00:00", "-t", "1970-01-01T04:00:00", "-z", "UTC",
+		"--split", "2h", "--split-concurrency", "2", "-m", "-O", "ndjson", "--poll-interval", "1ms")...)
+	if code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	for _, want := range []string{"splitting at 1970-01-01T01:00:00", "splitting at 1970-01-01T03:00:00"} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("stderr %q doesn't contain %q", stderr, want)
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 160 {
+		t.Fatalf("got %d messages, want 160", len(lines))
+	}
+	for i, line := range lines {
+		want := fmt.Sprintf(`"_messagetime":"%d"`, int64(i/40)*3600000+int64(i%40)*60000)
+		if !strings.Contains(line, want) {
+			t.Fatalf("line %d: %s doesn't contain %s", i+1, line, want)
+		}
+	}
+	if got := api.Requests("create"); got != 6 {
+		t.Errorf("created %d search jobs, want 6", got)
+	}
+	if ids := api.JobIds(); len(ids) != 0 {
+		t.Errorf("search jobs %v were not deleted", ids)
+	}
+}
@@ -5,12 +5,14 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/nhoag/sumo-search-job-cli/client"
 	openapi "github.com/nhoag/sumologic-search-job-client-go"
 
 	"github.com/spf13/cobra"
+
+	"github.com/nhoag/sumo-search-job-cli/client"
 )
 
 // jobStatusCheckCmd represents the jobStatusCheck command
@@ -19,13 +21,17 @@ var jobStatusCheckCmd = &cobra.Command{
 	Short: "Check the status for a Sumo Logic Search Job",
 	Long: `The jobStatusCheck command will check the status of a Sumo Logic
 	Search Job via the Search Job API.`,
+	Args:   cobra.ExactArgs(1),
+	PreRun: preparePolling,
 	Run: func(cmd *cobra.Command, args []string) {
 		QuietOpt, _ = cmd.Flags().GetBool("quiet")
 		VerboseOpt, _ = cmd.Flags().GetBool("verbose")
 		if VerboseOpt {
 			fmt.Fprintf(os.Stderr, "%d\tSTART\tjobStatusCheck\n", time.Now().UnixNano())
 		}
-		executeStatusCheck(cmd, args)
+		validateStatusCheck()
+		_, err := executeStatusCheck(cmd, args)
+		exitOnError(err)
 		if VerboseOpt {
 			fmt.Fprintf(os.Stderr, "%d\tEND\tjobStatusCheck\n", time.Now().UnixNano())
 		}
@@ -36,54 +42,107 @@ func validateStatusCheck() {
 	if VerboseOpt {
 		fmt.Fprintf(os.Stderr, "%d\tSTART\tjobStatusCheck::validateStatusCheck()\n", time.Now().UnixNano())
 	}
-
-	// Add validation logic here
+	validatePolling()
+	cobra.CheckErr(validateOutputFormat(HistogramOutputOpt))
 	if VerboseOpt {
 		fmt.Fprintf(os.Stderr, "%d\tEND\tjobStatusCheck::validateStatusCheck()\n", time.Now().UnixNano())
 	}
 }
 
-func executeStatusCheck(cmd *cobra.Command, args []string) *openapi.SearchJobState {
+func executeStatusCheck(cmd *cobra.Command, args []string) (*openapi.SearchJobState, error) {
+	return pollStatus(cmd, args[0], false)
+}
+
+// pollStatus prints the status of a search job, along with any warnings and
+// errors it reports, and polls until the job is done when --poll is set.
+// With failOnErrors, errors reported by the job (and warnings, with
+// --warnings-as-errors) and its cancellation stop polling with an error.
+func pollStatus(cmd *cobra.Command, jobId string, failOnErrors bool) (*openapi.SearchJobState, error) {
 	if VerboseOpt {
 		fmt.Fprintf(os.Stderr, "%d\tSTART\tjobStatusCheck::executeStatusCheck()\n", time.Now().UnixNano())
 	}
 	poll, _ := cmd.Flags().GetBool("poll")
+	withHistogram, _ := cmd.Flags().GetBool("histogram")
+	histogram := make(map[int64]client.HistogramBucket)
+	poller := newJobPoller(jobId)
 	var status *openapi.SearchJobState
+	var err error
 	for {
-		status = client.GetSearchJobStatus(args[0])
-		if !QuietOpt {
-			fmt.Fprintf(
-				os.Stderr,
-				"Status:\t\t%s\nMessage Count:\t%s\nRecord Count:\t%s\n",
-				*status.State,
-				strconv.FormatInt(int64(*status.MessageCount), 10),
-				strconv.FormatInt(int64(*status.RecordCount), 10),
-			)
+		if withHistogram {
+			var buckets []client.HistogramBucket
+			status, buckets, err = getClient().GetSearchJobHistogram(cmd.Context(), jobId)
+			mergeHistogram(histogram, buckets)
+		} else {
+			status, err = getClient().GetSearchJobStatus(cmd.Context(), jobId)
+		}
+		if err != nil {
+			return nil, err
+		}
+		printStatus(status)
+		poller.report(status)
+		if failOnErrors {
+			if err := poller.pendingError(); err != nil {
+				return nil, err
+			}
+			if status.GetState() == "CANCELLED" {
+				return nil, fmt.Errorf("search job %s was cancelled", jobId)
+			}
 		}
-		if !poll ||
-			*status.State == "DONE GATHERING RESULTS" ||
-			*status.State == "CANCELLED" ||
-			*status.State == "FORCE PAUSED" {
+		if !poll || jobDone(status) {
 			break
 		}
 		if VerboseOpt {
 			jsonStatus, err := json.Marshal(status)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, err.Error())
+				fmt.Fprintln(os.Stderr, err)
 			}
 			fmt.Fprintf(os.Stderr, "STATUS PAYLOAD: %s\n", string(jsonStatus))
-			fmt.Fprintf(os.Stderr, "%d\tSLEEP SECONDS:\t%d\n", time.Now().UnixNano(), SleepSecondsOpt)
 		}
-		time.Sleep(time.Duration(SleepSecondsOpt) * time.Second)
+		if err := poller.observe(status); err != nil {
+			return nil, err
+		}
+		if err := poller.wait(cmd.Context()); err != nil {
+			return nil, err
+		}
+	}
+	if withHistogram {
+		if err := printHistogram(sortedHistogram(histogram)); err != nil {
+			return nil, err
+		}
 	}
 	if VerboseOpt {
 		fmt.Fprintf(os.Stderr, "%d\tEND\tjobStatusCheck::executeStatusCheck()\n", time.Now().UnixNano())
 	}
-	return status
+	return status, nil
+}
+
+// printStatus prints the state and counts of a search job.
+func printStatus(status *openapi.SearchJobState) {
+	if QuietOpt {
+		return
+	}
+	fmt.Fprintf(
+		os.Stderr,
+		"Status:\t\t%s\nMessage Count:\t%s\nRecord Count:\t%s\n",
+		*status.State,
+		strconv.FormatInt(int64(*status.MessageCount), 10),
+		strconv.FormatInt(int64(*status.RecordCount), 10),
+	)
+}
+
+// jobDone reports whether a search job has stopped gathering results.
+func jobDone(status *openapi.SearchJobState) bool {
+	switch status.GetState() {
+	case "DONE GATHERING RESULTS", "CANCELLED", "FORCE PAUSED":
+		return true
+	}
+	return false
 }
 
 func init() {
 	rootCmd.AddCommand(jobStatusCheckCmd)
 	jobStatusCheckCmd.Flags().BoolP("poll", "p", false, "Poll for status until search job is complete")
-	jobStatusCheckCmd.Flags().Int32VarP(&SleepSecondsOpt, "sleep", "Z", 1, "Specify sleep seconds")
+	jobStatusCheckCmd.Flags().Bool("histogram", false, "Print the message histogram of the search window, and draw it as a sparkline")
+	jobStatusCheckCmd.Flags().StringVarP(&HistogramOutputOpt, "output", "O", "table", "Histogram output format ("+strings.Join(OutputFormats, ", ")+")")
+	jobStatusCheckCmd.Flags().Int32VarP(&SleepSecondsOpt, "sleep", "Z", 1, "Fixed seconds between status polls, instead of backing off")
 }
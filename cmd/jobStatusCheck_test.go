@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nhoag/sumo-search-job-cli/fakeserver"
+)
+
+func TestJobStatusCheck(t *testing.T) {
+	api := newFakeAPI(t, fakeserver.Fixture{Scenarios: []fakeserver.Scenario{
+		{Match: "cancel", States: []fakeserver.Step{{State: "GATHERING RESULTS"}, {State: "CANCELLED"}}},
+		{Match: "pause", States: []fakeserver.Step{{State: "GATHERING RESULTS"}, {State: "FORCE PAUSED"}}},
+		messageScenario("", 10),
+	}})
+	tests := []struct {
+		query string
+		args  []string
+		// states are the states reported on stderr, in order.
+		states []string
+	}{
+		{"error", nil, []string{"GATHERING RESULTS"}},
+		{"error", []string{"-p", "--poll-interval", "1ms"}, []string{"GATHERING RESULTS", "GATHERING RESULTS", "DONE GATHERING RESULTS"}},
+		// Checking the status of a cancelled or paused job is not an error.
+		{"cancel", []string{"-p", "--poll-interval", "1ms"}, []string{"GATHERING RESULTS", "CANCELLED"}},
+		{"pause", []string{"-p", "--poll-interval", "1ms"}, []string{"GATHERING RESULTS", "FORCE PAUSED"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query+strings.Join(tt.args, ""), func(t *testing.T) {
+			jobId := api.createJob(t, tt.query)
+			_, stderr, code := runCLI(t, api.args(append([]string{"jobStatusCheck", jobId}, tt.args...)...)...)
+			if code != 0 {
+				t.Fatalf("exit code %d: %s", code, stderr)
+			}
+			var states []string
+			for _, line := range strings.Split(stderr, "\n") {
+				if state, ok := strings.CutPrefix(line, "Status:\t\t"); ok {
+					states = append(states, state)
+				}
+			}
+			if strings.Join(states, ",") != strings.Join(tt.states, ",") {
+				t.Errorf("got states %q, want %q", states, tt.states)
+			}
+		})
+	}
+}
+
+func TestJobStatusCheckErrors(t *testing.T) {
+	fixture := fakeserver.Fixture{Scenarios: []fakeserver.Scenario{
+		{Match: "stuck", States: []fakeserver.Step{{State: "GATHERING RESULTS"}}},
+		messageScenario("", 10),
+	}}
+	tests := []struct {
+		name   string
+		query  string
+		jobId  string
+		fault  *fakeserver.Fault
+		args   []string
+		code   int
+		stderr string
+	}{
+		{name: "unknown job", jobId: "FAKE0000000000FF", code: 1, stderr: "Job ID is invalid"},
+		{name: "server error", query: "error", fault: &fakeserver.Fault{Op: "status", Status: 503}, args: []string{"--retry-max-attempts", "2"}, code: 1, stderr: "HTTP 503"},
+		{name: "timeout", query: "stuck", args: []string{"-p", "--poll-interval", "1ms", "--timeout", "20ms"}, code: ExitTimeout, stderr: "did not finish within 20ms"},
+		{name: "stalled", query: "stuck", args: []string{"-p", "--poll-interval", "1ms", "--poll-max-interval", "1ms", "--stall-polls", "3", "--on-stall", "fail"}, code: ExitStalled, stderr: "made no progress in 3 polls"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakeAPI(t, fixture)
+			jobId := tt.jobId
+			if len(jobId) == 0 {
+				jobId = api.createJob(t, tt.query)
+			}
+			if tt.fault != nil {
+				api.AddFault(*tt.fault)
+			}
+			_, stderr, code := runCLI(t, api.args(append([]string{"jobStatusCheck", jobId}, tt.args...)...)...)
+			if code != tt.code {
+				t.Errorf("exit code %d, want %d", code, tt.code)
+			}
+			if !strings.Contains(stderr, tt.stderr) {
+				t.Errorf("stderr %q doesn't contain %q", stderr, tt.stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	openapi "github.com/nhoag/sumologic-search-job-client-go"
+)
+
+var StreamOpt bool
+
+func validateStream() {
+	if !StreamOpt {
+		return
+	}
+	if len(SplitOpt) > 0 || len(SweepOpt) > 0 {
+		cobra.CheckErr(fmt.Errorf("stream can't be combined with split or sweep"))
+	}
+}
+
+// executeStreamResults writes the messages of a search job as they are
+// gathered: on every status poll it pages through the messages added since
+// the last one. Records are fetched once the job is done, because the
+// aggregates are recomputed while the job gathers results.
+func executeStreamResults(cmd *cobra.Command, jobId string) error {
+	if VerboseOpt {
+		fmt.Fprintf(os.Stderr, "%d\tSTART\tjobResultsGet::executeStreamResults()\n", time.Now().UnixNano())
+	}
+	ctx := cmd.Context()
+	messagesOnly, _ := cmd.Flags().GetBool("messages")
+	recordsOnly, _ := cmd.Flags().GetBool("records")
+	out := os.Stdout
+	if len(OutputFileOpt) > 0 {
+		file, err := os.Create(OutputFileOpt)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	writer, err := newResultWriter(out, OutputOpt)
+	if err != nil {
+		return err
+	}
+	if !messagesOnly && !recordsOnly {
+		writer.keyed("messages", "records")
+	}
+
+	msgOffset := OffsetOpt
+	written := 0
+	status, err := waitJob(ctx, jobId, func(status *openapi.SearchJobState) (bool, error) {
+		printStatus(status)
+		if available := status.GetMessageCount(); !recordsOnly && msgOffset < available {
+			n, err := fetchPages(ctx, messagePages(jobId), msgOffset, available, true, writer.WritePage)
+			if err != nil {
+				return false, err
+			}
+			msgOffset += int32(n)
+			written += n
+		}
+		return false, writer.Flush()
+	})
+	if err != nil {
+		return err
+	}
+	if err := writer.EndSection(); err != nil {
+		return err
+	}
+
+	if !messagesOnly {
+		n, err := fetchPages(ctx, recordPages(jobId), OffsetOpt, status.GetRecordCount(), true, writer.WritePage)
+		if err != nil {
+			return err
+		}
+		written += n
+		if err := writer.EndSection(); err != nil {
+			return err
+		}
+	}
+	if !QuietOpt && written == 0 {
+		fmt.Fprintf(os.Stderr, "No results for the specified search\n")
+	}
+	if VerboseOpt {
+		fmt.Fprintf(os.Stderr, "%d\tEND\tjobResultsGet::executeStreamResults()\n", time.Now().UnixNano())
+	}
+	return nil
+}
+
+func init() {
+	jobResultsGetCmd.Flags().BoolVar(&StreamOpt, "stream", false, "Write messages as the job gathers them instead of waiting for it to finish (implies --all and --poll)")
+	for _, flags := range processFullFlagSets() {
+		flags.BoolVar(&StreamOpt, "stream", false, "Write messages as the job gathers them instead of waiting for it to finish")
+	}
+}
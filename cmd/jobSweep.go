@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	openapi "github.com/nhoag/sumologic-search-job-client-go"
+
+	"github.com/nhoag/sumo-search-job-cli/client"
+)
+
+var (
+	SweepOpt            string
+	SweepConcurrencyOpt int
+)
+
+// sweepResult holds the tagged results of one sweep run.
+type sweepResult struct {
+	messageFields []client.Field
+	messages      []client.Row
+	recordFields  []client.Field
+	records       []client.Row
+}
+
+func validateSweep() {
+	if len(SweepOpt) == 0 {
+		return
+	}
+	if len(SplitOpt) > 0 {
+		cobra.CheckErr(fmt.Errorf("sweep and split can't be combined"))
+	}
+	if SweepConcurrencyOpt < 1 {
+		cobra.CheckErr(fmt.Errorf("sweep-concurrency must be at least 1"))
+	}
+}
+
+// readSweepFile returns the variables of each row of a CSV file whose header
+// names the variables.
+func readSweepFile(path string) ([]string, []map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(records) < 2 {
+		return nil, nil, fmt.Errorf("%s: expected a header row and at least one row of values", path)
+	}
+	header := records[0]
+	var rows []map[string]string
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			row[name] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return header, rows, nil
+}
+
+// executeSweep runs the templated search once per row of the sweep file, up
+// to --sweep-concurrency at a time, and writes the results in row order.
+// Every result row is tagged with the variables of its sweep row.
+func executeSweep(cmd *cobra.Command, args []string) error {
+	if VerboseOpt {
+		fmt.Fprintf(os.Stderr, "%d\tSTART\tjobProcessFull::executeSweep()\n", time.Now().UnixNano())
+	}
+	names, rows, err := readSweepFile(SweepOpt)
+	if err != nil {
+		return err
+	}
+	baseVars, err := templateVars()
+	if err != nil {
+		return err
+	}
+	// Resolve every definition up front so that template errors surface
+	// before any job is created.
+	jobDefs := make([]JobDefinition, len(rows))
+	for i, row := range rows {
+		vars := make(map[string]string, len(baseVars)+len(row))
+		for key, value := range baseVars {
+			vars[key] = value
+		}
+		for key, value := range row {
+			vars[key] = value
+		}
+		if jobDefs[i], _, err = resolveJobDefinition(cmd, vars); err != nil {
+			return fmt.Errorf("%s row %d: %w", SweepOpt, i+1, err)
+		}
+	}
+	writer, err := newResultWriter(os.Stdout, OutputOpt)
+	if err != nil {
+		return err
+	}
+	messagesOnly, _ := cmd.Flags().GetBool("messages")
+	recordsOnly, _ := cmd.Flags().GetBool("records")
+	if !messagesOnly && !recordsOnly {
+		writer.keyed("messages", "records")
+	}
+
+	// Messages are written as soon as a sweep row and the rows before it
+	// have finished. Records come after every message, so they are held
+	// until the last row; they are aggregates, and so are few.
+	total := 0
+	var records []sweepResult
+	err = runOrdered(cmd.Context(), len(rows), SweepConcurrencyOpt, func(ctx context.Context, i int) (sweepResult, error) {
+		result, err := runSweepJob(ctx, jobDefs[i], !recordsOnly, !messagesOnly)
+		if err != nil {
+			return result, fmt.Errorf("%s row %d: %w", SweepOpt, i+1, err)
+		}
+		tagRows(&result, names, rows[i])
+		return result, nil
+	}, func(i int, result sweepResult) error {
+		if len(result.records) > 0 {
+			records = append(records, sweepResult{recordFields: result.recordFields, records: result.records})
+		}
+		if len(result.messages) == 0 {
+			return nil
+		}
+		total += len(result.messages)
+		return writer.WritePage(result.messageFields, result.messages)
+	})
+	if err != nil {
+		return err
+	}
+	if err := writer.EndSection(); err != nil {
+		return err
+	}
+	for _, result := range records {
+		if err := writer.WritePage(result.recordFields, result.records); err != nil {
+			return err
+		}
+		total += len(result.records)
+	}
+	if err := writer.EndSection(); err != nil {
+		return err
+	}
+	if !QuietOpt && total == 0 {
+		fmt.Fprintf(os.Stderr, "No results for the specified search\n")
+	}
+	if VerboseOpt {
+		fmt.Fprintf(os.Stderr, "%d\tEND\tjobProcessFull::executeSweep()\n", time.Now().UnixNano())
+	}
+	return nil
+}
+
+// runSweepJob runs a search job and fetches all of its messages and records
+// as requested.
+func runSweepJob(ctx context.Context, jobDef JobDefinition, messages bool, records bool) (sweepResult, error) {
+	var result sweepResult
+	err := runJob(ctx, jobDef, nil, func(jobId string, status *openapi.SearchJobState) error {
+		var err error
+		if messages {
+			result.messageFields, result.messages, err = collectPages(ctx, messagePages(jobId), status.GetMessageCount())
+			if err != nil {
+				return err
+			}
+		}
+		if records {
+			result.recordFields, result.records, err = collectPages(ctx, recordPages(jobId), status.GetRecordCount())
+		}
+		return err
+	})
+	if err != nil {
+		return sweepResult{}, err
+	}
+	return result, nil
+}
+
+// tagRows adds the sweep variables to every row, as leading columns. They
+// replace result fields of the same name.
+func tagRows(result *sweepResult, names []string, vars map[string]string) {
+	tag := func(fields []client.Field, rows []client.Row) []client.Field {
+		for _, row := range rows {
+			for _, name := range names {
+				row[name] = vars[name]
+			}
+		}
+		if len(rows) == 0 {
+			return fields
+		}
+		tagged := make([]client.Field, 0, len(names)+len(fields))
+		for _, name := range names {
+			tagged = append(tagged, client.Field{Name: name, FieldType: "string"})
+		}
+		for _, field := range fields {
+			if _, ok := vars[field.Name]; !ok {
+				tagged = append(tagged, field)
+			}
+		}
+		return tagged
+	}
+	result.messageFields = tag(result.messageFields, result.messages)
+	result.recordFields = tag(result.recordFields, result.records)
+}
+
+func init() {
+	for _, flags := range processFullFlagSets() {
+		flags.StringVar(&SweepOpt, "sweep", "", "Run the templated search once per row of this CSV file, whose header names the variables")
+		flags.IntVar(&SweepConcurrencyOpt, "sweep-concurrency", 4, "Maximum number of concurrent sweep jobs")
+	}
+}
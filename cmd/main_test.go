@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nhoag/sumo-search-job-cli/fakeserver"
+)
+
+// cliArgsEnv holds the JSON arguments of a CLI run in a child process
+// started by startCLI.
+const cliArgsEnv = "SUMO_TEST_CLI_ARGS"
+
+// TestMain runs the CLI instead of the tests in the child processes of
+// runCLI, so that exit codes and signals behave as in the real binary.
+func TestMain(m *testing.M) {
+	if args, ok := os.LookupEnv(cliArgsEnv); ok {
+		var argv []string
+		if err := json.Unmarshal([]byte(args), &argv); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		rootCmd.SetArgs(argv)
+		Execute()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// syncBuffer is a bytes.Buffer that can be read while a child process
+// writes to it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// cliRun is a CLI run in a child process.
+type cliRun struct {
+	cmd    *exec.Cmd
+	stdout syncBuffer
+	stderr syncBuffer
+}
+
+// startCLI starts the CLI with args in a child process, with a home
+// directory of its own.
+func startCLI(t *testing.T, home string, args ...string) *cliRun {
+	t.Helper()
+	argv, err := json.Marshal(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := &cliRun{cmd: exec.Command(os.Args[0])}
+	run.cmd.Env = []string{
+		cliArgsEnv + "=" + string(argv),
+		"HOME=" + home,
+		"XDG_CONFIG_HOME=" + filepath.Join(home, ".config"),
+		"XDG_CACHE_HOME=" + filepath.Join(home, ".cache"),
+		"PATH=" + os.Getenv("PATH"),
+	}
+	run.cmd.Stdout = &run.stdout
+	run.cmd.Stderr = &run.stderr
+	if err := run.cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return run
+}
+
+// wait waits for the run to exit and returns its exit code.
+func (run *cliRun) wait(t *testing.T) int {
+	t.Helper()
+	err := run.cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return 0
+}
+
+// runCLI runs the CLI with args in a child process and returns its stdout,
+// stderr and exit code.
+func runCLI(t *testing.T, args ...string) (string, string, int) {
+	t.Helper()
+	run := startCLI(t, t.TempDir(), args...)
+	code := run.wait(t)
+	return run.stdout.String(), run.stderr.String(), code
+}
+
+// fakeAPI is a fake Search Job API server for CLI runs.
+type fakeAPI struct {
+	*fakeserver.Server
+	endpoint string
+}
+
+// newFakeAPI serves fixture until the test ends.
+func newFakeAPI(t *testing.T, fixture fakeserver.Fixture) fakeAPI {
+	t.Helper()
+	server := fakeserver.New(fakeserver.Config{Fixture: fixture})
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+	return fakeAPI{Server: server, endpoint: srv.URL + "/api"}
+}
+
+// args appends the flags that point the CLI at the server to args.
+func (api fakeAPI) args(args ...string) []string {
+	return append(args, "--endpoint", api.endpoint, "--rate-limit", "0", "--retry-base-delay", "1ms")
+}
+
+// createJob creates a search job for query without going through the CLI.
+func (api fakeAPI) createJob(t *testing.T, query string) string {
+	t.Helper()
+	body := strings.NewReader(fmt.Sprintf(`{"query":%q,"from":"2024-01-01T00:00:00","to":"2024-01-01T01:00:00","timeZone":"UTC"}`, query))
+	resp, err := http.Post(api.endpoint+"/v1/search/jobs", "application/json", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	return created.ID
+}
+
+// deleteJob deletes a search job without going through the CLI.
+func (api fakeAPI) deleteJob(t *testing.T, jobId string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodDelete, api.endpoint+"/v1/search/jobs/"+jobId, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("deleting search job %s: %s", jobId, resp.Status)
+	}
+}
+
+// messageScenario returns a scenario of n messages that gathers for two
+// polls.
+func messageScenario(match string, n int) fakeserver.Scenario {
+	scenario := fakeserver.Scenario{
+		Match: match,
+		States: []fakeserver.Step{
+			{State: "GATHERING RESULTS", Polls: 2},
+			{State: "DONE GATHERING RESULTS"},
+		},
+		Fields: []fakeserver.Field{{Name: "_messageid"}, {Name: "_raw"}},
+	}
+	for i := 0; i < n; i++ {
+		scenario.Messages = append(scenario.Messages, map[string]string{"_messageid": fmt.Sprint(i), "_raw": fmt.Sprintf("m%d", i)})
+	}
+	return scenario
+}
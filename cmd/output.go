@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nhoag/sumo-search-job-cli/client"
+)
+
+// OutputFormats lists the values accepted by --output.
+var OutputFormats = []string{"json", "ndjson", "csv", "tsv", "table"}
+
+// resultWriter streams message or record rows in one output format. Rows are
+// grouped in sections, one per result kind; a section's columns are fixed by
+// the fields metadata of its first page.
+type resultWriter struct {
+	out      io.Writer
+	format   string
+	columns  []string
+	rows     int
+	sections int
+	started  bool
+	csv      *csv.Writer
+	table    *tabwriter.Writer
+	// keys, if set, name the sections of json output, which is then one
+	// object with an array per section instead of an array per section.
+	keys  []string
+	ended int
+}
+
+// writerState is the position of a resultWriter in its output. Checkpoints
+// save it so that an interrupted export can be continued.
+type writerState struct {
+	Columns  []string `json:"columns,omitempty"`
+	Rows     int      `json:"rows"`
+	Sections int      `json:"sections"`
+	Started  bool     `json:"started"`
+	Ended    int      `json:"ended,omitempty"`
+}
+
+func (w *resultWriter) state() writerState {
+	return writerState{Columns: w.columns, Rows: w.rows, Sections: w.sections, Started: w.started, Ended: w.ended}
+}
+
+// restore continues output that was written up to state. Tables can't be
+// continued, since their columns are aligned over all rows.
+func (w *resultWriter) restore(state writerState) {
+	w.columns = state.Columns
+	w.rows = state.Rows
+	w.sections = state.Sections
+	w.started = state.Started
+	w.ended = state.Ended
+	if w.started && (w.format == "csv" || w.format == "tsv") {
+		w.csv = csv.NewWriter(w.out)
+		if w.format == "tsv" {
+			w.csv.Comma = '\t'
+		}
+	}
+}
+
+func validateOutputFormat(format string) error {
+	for _, valid := range OutputFormats {
+		if format == valid {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid output format %q (valid: %s)", format, strings.Join(OutputFormats, ", "))
+}
+
+func newResultWriter(out io.Writer, format string) (*resultWriter, error) {
+	if err := validateOutputFormat(format); err != nil {
+		return nil, err
+	}
+	return &resultWriter{out: out, format: format}, nil
+}
+
+// keyed names the sections that will be written, in order, so that json
+// output is a single document: {"messages": [...], "records": [...]}. Every
+// named section must be ended, even when it has no rows. Other formats are
+// not affected.
+func (w *resultWriter) keyed(keys ...string) {
+	if w.format == "json" {
+		w.keys = keys
+	}
+}
+
+// columnNames returns the column order for a section: the order of the
+// fields metadata, or the sorted keys of the first row when there is none.
+func columnNames(fields []client.Field, rows []client.Row) []string {
+	var columns []string
+	for _, field := range fields {
+		columns = append(columns, field.Name)
+	}
+	if len(columns) == 0 && len(rows) > 0 {
+		for key := range rows[0] {
+			columns = append(columns, key)
+		}
+		sort.Strings(columns)
+	}
+	return columns
+}
+
+// WritePage writes the rows of a page, starting a new section if needed.
+func (w *resultWriter) WritePage(fields []client.Field, rows []client.Row) error {
+	if !w.started {
+		if err := w.begin(columnNames(fields, rows)); err != nil {
+			return err
+		}
+	}
+	for _, row := range rows {
+		if err := w.writeRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EndSection finishes the current section, if one was started. Keyed json
+// sections that were never started are written as empty arrays.
+func (w *resultWriter) EndSection() error {
+	if len(w.keys) > 0 {
+		return w.endKeyed()
+	}
+	if !w.started {
+		return nil
+	}
+	w.started = false
+	switch w.format {
+	case "json":
+		if w.rows > 0 {
+			_, err := io.WriteString(w.out, "\n]\n")
+			return err
+		}
+		_, err := io.WriteString(w.out, "]\n")
+		return err
+	case "csv", "tsv":
+		w.csv.Flush()
+		return w.csv.Error()
+	case "table":
+		return w.table.Flush()
+	}
+	return nil
+}
+
+// Flush writes out buffered rows, for output that is followed as it is
+// produced. Table columns are only aligned within the rows of each flush.
+func (w *resultWriter) Flush() error {
+	if !w.started {
+		return nil
+	}
+	switch w.format {
+	case "csv", "tsv":
+		w.csv.Flush()
+		return w.csv.Error()
+	case "table":
+		return w.table.Flush()
+	}
+	return nil
+}
+
+// endKeyed ends a section of keyed json output, and the document after the
+// last section.
+func (w *resultWriter) endKeyed() error {
+	end := "]"
+	if !w.started {
+		end = w.sectionKey() + "[]"
+	} else if w.rows > 0 {
+		end = "\n  ]"
+	}
+	w.started = false
+	w.ended++
+	if w.ended == len(w.keys) {
+		end += "\n}\n"
+	}
+	_, err := io.WriteString(w.out, end)
+	return err
+}
+
+// sectionKey opens the next section of keyed json output.
+func (w *resultWriter) sectionKey() string {
+	key := "messages"
+	if w.ended < len(w.keys) {
+		key = w.keys[w.ended]
+	}
+	separator := "{\n  "
+	if w.ended > 0 {
+		separator = ",\n  "
+	}
+	return fmt.Sprintf("%s%q: ", separator, key)
+}
+
+func (w *resultWriter) begin(columns []string) error {
+	w.columns = columns
+	w.rows = 0
+	w.started = true
+	w.sections++
+	if len(w.keys) > 0 {
+		_, err := io.WriteString(w.out, w.sectionKey()+"[")
+		return err
+	}
+	if w.sections > 1 && w.format != "ndjson" {
+		if _, err := io.WriteString(w.out, "\n"); err != nil {
+			return err
+		}
+	}
+	switch w.format {
+	case "json":
+		_, err := io.WriteString(w.out, "[")
+		return err
+	case "csv", "tsv":
+		w.csv = csv.NewWriter(w.out)
+		if w.format == "tsv" {
+			w.csv.Comma = '\t'
+		}
+		return w.csv.Write(columns)
+	case "table":
+		w.table = tabwriter.NewWriter(w.out, 0, 0, 2, ' ', 0)
+		_, err := fmt.Fprintln(w.table, strings.Join(columns, "\t"))
+		return err
+	}
+	return nil
+}
+
+func (w *resultWriter) writeRow(row client.Row) error {
+	defer func() { w.rows++ }()
+	switch w.format {
+	case "json":
+		content, err := json.MarshalIndent(row, "    ", "    ")
+		if err != nil {
+			return err
+		}
+		separator := "\n    "
+		if w.rows > 0 {
+			separator = ",\n    "
+		}
+		_, err = io.WriteString(w.out, separator+string(content))
+		return err
+	case "ndjson":
+		content, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+		_, err = w.out.Write(append(content, '\n'))
+		return err
+	case "csv", "tsv":
+		return w.csv.Write(w.values(row, false))
+	case "table":
+		_, err := fmt.Fprintln(w.table, strings.Join(w.values(row, true), "\t"))
+		return err
+	}
+	return nil
+}
+
+// values returns the row's values in column order. Table cells are flattened
+// onto one line so they don't break the layout.
+func (w *resultWriter) values(row client.Row, flatten bool) []string {
+	values := make([]string, len(w.columns))
+	for i, column := range w.columns {
+		value := row[column]
+		if flatten {
+			value = strings.NewReplacer("\n", `\n`, "\t", `\t`, "\r", "").Replace(value)
+		}
+		values[i] = value
+	}
+	return values
+}
@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nhoag/sumo-search-job-cli/client"
+)
+
+const (
+	// maxPageSize is the largest page the Search Job API returns.
+	maxPageSize = 10000
+	// minAutoPageSize is the smallest page chosen by --limit 0, and the size
+	// of a single page fetched without --all.
+	minAutoPageSize = 100
+)
+
+var ParallelOpt int
+
+// pageDelay is the pause before each result page after the first, set from
+// --sleep with --parallel 1.
+var pageDelay time.Duration
+
+// pageFetcher fetches one page of a search job's messages or records.
+type pageFetcher func(ctx context.Context, limit int32, offset int32) ([]client.Field, []client.Row, error)
+
+type pageResult struct {
+	fields []client.Field
+	rows   []client.Row
+}
+
+func validatePaging() {
+	if LimitOpt < 0 || LimitOpt > maxPageSize {
+		cobra.CheckErr(fmt.Errorf("limit must be between 1 and %d, or 0 to choose the page size automatically", maxPageSize))
+	}
+	if OffsetOpt < 0 {
+		cobra.CheckErr(fmt.Errorf("offset can't be negative"))
+	}
+	if ParallelOpt < 1 {
+		cobra.CheckErr(fmt.Errorf("parallel must be at least 1"))
+	}
+}
+
+func messagePages(jobId string) pageFetcher {
+	return func(ctx context.Context, limit int32, offset int32) ([]client.Field, []client.Row, error) {
+		page, err := getClient().GetSearchJobMessages(ctx, jobId, limit, offset)
+		if err != nil {
+			return nil, nil, err
+		}
+		return page.Fields, page.Rows(), nil
+	}
+}
+
+func recordPages(jobId string) pageFetcher {
+	return func(ctx context.Context, limit int32, offset int32) ([]client.Field, []client.Row, error) {
+		page, err := getClient().GetSearchJobRecords(ctx, jobId, limit, offset)
+		if err != nil {
+			return nil, nil, err
+		}
+		return page.Fields, page.Rows(), nil
+	}
+}
+
+// pageSize returns --limit, or for --limit 0 a size that spreads the
+// remaining results over --parallel pages, within the API maximum.
+func pageSize(remaining int32, all bool) int32 {
+	if LimitOpt > 0 {
+		return LimitOpt
+	}
+	if !all {
+		return minAutoPageSize
+	}
+	size := (remaining + int32(ParallelOpt) - 1) / int32(ParallelOpt)
+	return min(max(size, minAutoPageSize), maxPageSize)
+}
+
+// fetchPages fetches the results from offset up to total, or only the first
+// page unless all is set, and passes each page to write in offset order.
+// Up to --parallel pages are fetched at once, and every page after the first
+// waits pageDelay. It returns the number of rows written.
+func fetchPages(ctx context.Context, fetch pageFetcher, offset int32, total int32, all bool, write func([]client.Field, []client.Row) error) (int, error) {
+	type pageRange struct {
+		offset int32
+		limit  int32
+	}
+	var pages []pageRange
+	for start := offset; start < total; {
+		limit := min(pageSize(total-start, all), total-start)
+		pages = append(pages, pageRange{start, limit})
+		start += limit
+		if !all {
+			break
+		}
+	}
+
+	written := 0
+	err := runOrdered(ctx, len(pages), ParallelOpt, func(ctx context.Context, i int) (pageResult, error) {
+		if i > 0 && pageDelay > 0 {
+			if err := sleep(ctx, pageDelay); err != nil {
+				return pageResult{}, err
+			}
+		}
+		fields, rows, err := fetch(ctx, pages[i].limit, pages[i].offset)
+		return pageResult{fields: fields, rows: rows}, err
+	}, func(i int, page pageResult) error {
+		if err := write(page.fields, page.rows); err != nil {
+			return err
+		}
+		written += len(page.rows)
+		return nil
+	})
+	return written, err
+}
+
+// collectPages fetches all results up to total and returns them as a single
+// page.
+func collectPages(ctx context.Context, fetch pageFetcher, total int32) ([]client.Field, []client.Row, error) {
+	var fields []client.Field
+	var rows []client.Row
+	_, err := fetchPages(ctx, fetch, 0, total, true, func(pageFields []client.Field, pageRows []client.Row) error {
+		if len(fields) == 0 {
+			fields = pageFields
+		}
+		rows = append(rows, pageRows...)
+		return nil
+	})
+	return fields, rows, err
+}
+
+// runOrdered calls run for the items 0 to n-1, up to parallel at a time, and
+// passes each result to write in item order. Results that finish before the
+// ones ahead of them are held in memory, so an item is only started when at
+// most 2*parallel items are waiting to be written.
+func runOrdered[T any](ctx context.Context, n int, parallel int, run func(ctx context.Context, i int) (T, error), write func(i int, result T) error) error {
+	type result struct {
+		value T
+		err   error
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	slots := make(chan struct{}, parallel)
+	ahead := make(chan struct{}, 2*parallel)
+	results := make([]chan result, n)
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+	go func() {
+		for i := range n {
+			select {
+			case ahead <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func() {
+				defer func() { <-slots }()
+				value, err := run(ctx, i)
+				results[i] <- result{value: value, err: err}
+			}()
+		}
+	}()
+
+	for i := range results {
+		var r result
+		select {
+		case r = <-results[i]:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		<-ahead
+		if r.err != nil {
+			return r.err
+		}
+		if err := write(i, r.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	jobResultsGetCmd.Flags().IntVar(&ParallelOpt, "parallel", 4, "Maximum number of result pages fetched at once")
+	for _, flags := range processFullFlagSets() {
+		flags.IntVar(&ParallelOpt, "parallel", 4, "Maximum number of result pages fetched at once")
+	}
+}
@@ -0,0 +1,267 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	openapi "github.com/nhoag/sumologic-search-job-client-go"
+)
+
+const (
+	// ExitTimeout is the exit code when a search job doesn't finish within
+	// --timeout.
+	ExitTimeout = 3
+	// ExitStalled is the exit code when a gathering search job makes no
+	// progress for --stall-polls polls and --on-stall is fail.
+	ExitStalled = 4
+	// ExitNotStarted is the exit code when a search job stays NOT STARTED for
+	// --not-started-timeout and --on-stall is fail.
+	ExitNotStarted = 5
+)
+
+// StallActions lists the values accepted by --on-stall.
+var StallActions = []string{"warn", "fail"}
+
+var (
+	PollIntervalOpt      time.Duration
+	PollMaxIntervalOpt   time.Duration
+	TimeoutOpt           time.Duration
+	StallPollsOpt        int
+	NotStartedTimeoutOpt time.Duration
+	OnStallOpt           string
+	WarningsAsErrorsOpt  bool
+)
+
+// pollError is a polling failure with its own exit code.
+type pollError struct {
+	code    int
+	message string
+}
+
+func (e *pollError) Error() string {
+	return e.message
+}
+
+// ExitCode returns the process exit code for the error.
+func (e *pollError) ExitCode() int {
+	return e.code
+}
+
+// preparePolling makes an explicit --sleep a fixed polling interval, as it
+// was before polling backed off. On commands that fetch results it also
+// paces the result pages, fetching them one at a time, as they were before
+// --parallel.
+func preparePolling(cmd *cobra.Command, args []string) {
+	flags := cmd.Flags()
+	pageDelay = 0
+	if !flags.Changed("sleep") {
+		return
+	}
+	if flags.Lookup("parallel") != nil {
+		if flags.Changed("parallel") && ParallelOpt != 1 {
+			cobra.CheckErr(fmt.Errorf("sleep fetches result pages one at a time and can't be combined with parallel %d", ParallelOpt))
+		}
+		ParallelOpt = 1
+		pageDelay = time.Duration(SleepSecondsOpt) * time.Second
+	}
+	if flags.Changed("poll-interval") || flags.Changed("poll-max-interval") {
+		return
+	}
+	PollIntervalOpt = time.Duration(SleepSecondsOpt) * time.Second
+	PollMaxIntervalOpt = PollIntervalOpt
+}
+
+func validatePolling() {
+	if PollIntervalOpt <= 0 {
+		cobra.CheckErr(fmt.Errorf("poll-interval must be positive"))
+	}
+	if PollMaxIntervalOpt < PollIntervalOpt {
+		cobra.CheckErr(fmt.Errorf("poll-max-interval must be at least poll-interval"))
+	}
+	if TimeoutOpt < 0 || NotStartedTimeoutOpt < 0 || StallPollsOpt < 0 {
+		cobra.CheckErr(fmt.Errorf("timeout, not-started-timeout and stall-polls can't be negative"))
+	}
+	for _, action := range StallActions {
+		if OnStallOpt == action {
+			return
+		}
+	}
+	cobra.CheckErr(fmt.Errorf("invalid on-stall action %q (valid: %s)", OnStallOpt, strings.Join(StallActions, ", ")))
+}
+
+// jobPoller paces the status polls of one search job. The interval starts
+// at --poll-interval and doubles after every poll up to
+// --poll-max-interval. It enforces --timeout and detects jobs that stall.
+type jobPoller struct {
+	jobId    string
+	start    time.Time
+	interval time.Duration
+
+	polls      int
+	messages   int32
+	records    int32
+	unchanged  int
+	notStarted time.Time
+	warned     map[int]bool
+
+	// The pending warnings and errors reported so far, which the API only
+	// returns until they have been read.
+	pending  map[string]bool
+	warnings []string
+	errors   []string
+}
+
+func newJobPoller(jobId string) *jobPoller {
+	return &jobPoller{
+		jobId:    jobId,
+		start:    time.Now(),
+		interval: PollIntervalOpt,
+		warned:   make(map[int]bool),
+		pending:  make(map[string]bool),
+	}
+}
+
+// report prints the pending warnings and errors of a polled status that
+// haven't been printed before.
+func (p *jobPoller) report(status *openapi.SearchJobState) {
+	for _, kind := range []struct {
+		label string
+		items []interface{}
+		list  *[]string
+	}{
+		{"Warning", status.GetPendingWarnings(), &p.warnings},
+		{"Error", status.GetPendingErrors(), &p.errors},
+	} {
+		for _, item := range kind.items {
+			message := pendingMessage(item)
+			if p.pending[kind.label+message] {
+				continue
+			}
+			p.pending[kind.label+message] = true
+			*kind.list = append(*kind.list, message)
+			if !QuietOpt {
+				fmt.Fprintf(os.Stderr, "%s:\t%s (search job %s)\n", kind.label, message, p.jobId)
+			}
+		}
+	}
+}
+
+// pendingError returns the errors reported for the job, and its warnings
+// with --warnings-as-errors, as one error.
+func (p *jobPoller) pendingError() error {
+	if len(p.errors) > 0 {
+		return fmt.Errorf("search job %s failed: %s", p.jobId, strings.Join(p.errors, "; "))
+	}
+	if WarningsAsErrorsOpt && len(p.warnings) > 0 {
+		return fmt.Errorf("search job %s reported warnings: %s", p.jobId, strings.Join(p.warnings, "; "))
+	}
+	return nil
+}
+
+// pendingMessage renders a pending warning or error, which is usually a
+// string.
+func pendingMessage(item interface{}) string {
+	if message, ok := item.(string); ok {
+		return message
+	}
+	content, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Sprint(item)
+	}
+	return string(content)
+}
+
+// observe checks a polled status of an unfinished job for timeouts and
+// stalls.
+func (p *jobPoller) observe(status *openapi.SearchJobState) error {
+	now := time.Now()
+	if TimeoutOpt > 0 && now.Sub(p.start) >= TimeoutOpt {
+		return &pollError{ExitTimeout, fmt.Sprintf("search job %s did not finish within %s", p.jobId, TimeoutOpt)}
+	}
+	messages, records := status.GetMessageCount(), status.GetRecordCount()
+	progressed := p.polls == 0 || messages != p.messages || records != p.records
+	p.polls++
+	p.messages, p.records = messages, records
+
+	switch status.GetState() {
+	case "NOT STARTED":
+		if p.notStarted.IsZero() {
+			p.notStarted = now
+		}
+		if NotStartedTimeoutOpt > 0 && now.Sub(p.notStarted) >= NotStartedTimeoutOpt {
+			return p.stall(ExitNotStarted, fmt.Sprintf("search job %s has not started within %s", p.jobId, NotStartedTimeoutOpt))
+		}
+	case "GATHERING RESULTS":
+		p.notStarted = time.Time{}
+		if progressed {
+			p.unchanged = 0
+			delete(p.warned, ExitStalled)
+			break
+		}
+		p.unchanged++
+		if StallPollsOpt > 0 && p.unchanged >= StallPollsOpt {
+			return p.stall(ExitStalled, fmt.Sprintf("search job %s made no progress in %d polls (%d messages, %d records)", p.jobId, p.unchanged, messages, records))
+		}
+	}
+	return nil
+}
+
+// stall fails with code when --on-stall is fail, and otherwise warns once
+// per stall.
+func (p *jobPoller) stall(code int, message string) error {
+	if OnStallOpt == "fail" {
+		return &pollError{code, message}
+	}
+	if !p.warned[code] && !QuietOpt {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", message)
+	}
+	p.warned[code] = true
+	return nil
+}
+
+// wait sleeps until the next poll. The last wait is shortened so that
+// --timeout is honored.
+func (p *jobPoller) wait(ctx context.Context) error {
+	delay := p.interval
+	if TimeoutOpt > 0 {
+		if remaining := TimeoutOpt - time.Since(p.start); remaining < delay {
+			delay = max(remaining, 0)
+		}
+	}
+	if VerboseOpt {
+		fmt.Fprintf(os.Stderr, "%d\tSLEEP:\t%s\n", time.Now().UnixNano(), delay)
+	}
+	if err := sleep(ctx, delay); err != nil {
+		return err
+	}
+	p.interval = min(2*p.interval, PollMaxIntervalOpt)
+	return nil
+}
+
+// addPollFlags defines the polling flags of commands that wait for jobs.
+func addPollFlags(flags *pflag.FlagSet) {
+	flags.DurationVar(&PollIntervalOpt, "poll-interval", 500*time.Millisecond, "Delay before the second status poll, doubled after every poll")
+	flags.DurationVar(&PollMaxIntervalOpt, "poll-max-interval", 10*time.Second, "Upper bound for the delay between status polls")
+	flags.DurationVar(&TimeoutOpt, "timeout", 0, "Give up waiting for the search job after this long (0 waits forever)")
+	flags.IntVar(&StallPollsOpt, "stall-polls", 30, "Polls without new messages or records, while gathering results, before the job counts as stalled (0 disables)")
+	flags.DurationVar(&NotStartedTimeoutOpt, "not-started-timeout", 5*time.Minute, "Time in NOT STARTED before the job counts as stalled (0 disables)")
+	flags.StringVar(&OnStallOpt, "on-stall", "warn", "What to do when a job stalls ("+strings.Join(StallActions, ", ")+")")
+}
+
+func init() {
+	addPollFlags(jobStatusCheckCmd.Flags())
+	addPollFlags(jobResultsGetCmd.Flags())
+
+	jobResultsGetCmd.Flags().BoolVar(&WarningsAsErrorsOpt, "warnings-as-errors", false, "Fail when the search job reports warnings, as it does for errors")
+	for _, flags := range processFullFlagSets() {
+		addPollFlags(flags)
+		flags.BoolVar(&WarningsAsErrorsOpt, "warnings-as-errors", false, "Fail when the search job reports warnings, as it does for errors")
+	}
+}
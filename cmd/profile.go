@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// profileFlagDefaults maps profile settings to the command flags they provide
+// defaults for. The timezone and auto_parse settings are merged into job
+// definitions by resolveJobDefinition instead.
+var profileFlagDefaults = map[string]string{
+	"limit": "limit",
+}
+
+// profileNames returns the names of the profiles in the config file. Viper
+// lower-cases keys, so names are case-insensitive.
+func profileNames() []string {
+	var names []string
+	for name := range viper.GetStringMap("profiles") {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// profileSettings returns the settings of a named profile.
+func profileSettings(name string) (map[string]interface{}, error) {
+	key := "profiles." + strings.ToLower(name)
+	if !viper.IsSet(key) {
+		return nil, fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(profileNames(), ", "))
+	}
+	return viper.GetStringMap(key), nil
+}
+
+// applyProfile layers the active profile, selected with --profile,
+// SUMO_PROFILE or the profile config key, over the top-level settings of the
+// config file. Flags and environment variables still take precedence. The
+// limit setting then becomes the default of the --limit flag of cmd.
+func applyProfile(cmd *cobra.Command) error {
+	name := viper.GetString("profile")
+	if len(name) > 0 {
+		settings, err := profileSettings(name)
+		if err != nil {
+			return err
+		}
+		if err := viper.MergeConfigMap(settings); err != nil {
+			return err
+		}
+	}
+	for key, flagName := range profileFlagDefaults {
+		flag := cmd.Flags().Lookup(flagName)
+		if flag == nil || flag.Changed || !viper.InConfig(key) {
+			continue
+		}
+		if err := flag.Value.Set(viper.GetString(key)); err != nil {
+			return fmt.Errorf("invalid %s setting: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,334 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/nhoag/sumo-search-job-cli/queries"
+)
+
+var (
+	QueryDescriptionOpt string
+	QueryForceOpt       bool
+	QueryGlobalOpt      bool
+	QueryProjectOpt     bool
+)
+
+// queryCmd represents the query command
+var queryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "Manage a library of saved queries",
+	Long: `The query command saves named queries, along with their default time
+	windows, auto-parsing modes and template variables, and runs them.
+
+	Queries are stored as YAML files in the nearest .sumo/queries directory,
+	found by walking up from the current directory, or in the user query
+	directory (queries in the user config directory, or query_dir in the
+	config file). Project queries shadow user queries of the same name.`,
+}
+
+var querySaveCmd = &cobra.Command{
+	Use:   "save NAME",
+	Short: "Save a named query",
+	Long: `The save command stores the query and window given by the flags under
+	NAME. Any field may contain template placeholders such as {{.service}},
+	whose default values are set with --var and --vars-file. The query is
+	saved to the nearest project query directory if there is one, and to the
+	user query directory otherwise.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		QuietOpt, _ = cmd.Flags().GetBool("quiet")
+		VerboseOpt, _ = cmd.Flags().GetBool("verbose")
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tSTART\tquery save\n", time.Now().UnixNano())
+		}
+		if QueryGlobalOpt && QueryProjectOpt {
+			cobra.CheckErr(fmt.Errorf("global and project can't be combined"))
+		}
+		exitOnError(executeQuerySave(cmd, args))
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tEND\tquery save\n", time.Now().UnixNano())
+		}
+	},
+}
+
+var queryListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the saved queries",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		QuietOpt, _ = cmd.Flags().GetBool("quiet")
+		VerboseOpt, _ = cmd.Flags().GetBool("verbose")
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tSTART\tquery list\n", time.Now().UnixNano())
+		}
+		exitOnError(executeQueryList(cmd, args))
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tEND\tquery list\n", time.Now().UnixNano())
+		}
+	},
+}
+
+var queryShowCmd = &cobra.Command{
+	Use:   "show NAME",
+	Short: "Print a saved query",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		QuietOpt, _ = cmd.Flags().GetBool("quiet")
+		VerboseOpt, _ = cmd.Flags().GetBool("verbose")
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tSTART\tquery show\n", time.Now().UnixNano())
+		}
+		exitOnError(executeQueryShow(cmd, args))
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tEND\tquery show\n", time.Now().UnixNano())
+		}
+	},
+}
+
+var queryRunCmd = &cobra.Command{
+	Use:   "run NAME",
+	Short: "Run a saved query",
+	Long: `The run command performs the full process of jobProcessFull for a saved
+	query: it creates the search job, polls for completion, fetches the results
+	and deletes the job. It accepts all of the flags of jobProcessFull, which
+	override the fields and variables of the saved query.`,
+	Args:   cobra.ExactArgs(1),
+	PreRun: preparePolling,
+	Run: func(cmd *cobra.Command, args []string) {
+		QuietOpt, _ = cmd.Flags().GetBool("quiet")
+		VerboseOpt, _ = cmd.Flags().GetBool("verbose")
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tSTART\tquery run\n", time.Now().UnixNano())
+		}
+		query, err := queryLibrary().Load(args[0])
+		exitOnError(err)
+		savedQuery = query
+		validateProcessFull(cmd)
+		if PrintJobOpt {
+			exitOnError(executePrintJob(cmd, args[1:]))
+			return
+		}
+		exitOnError(executeProcessFull(cmd, args[1:]))
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tEND\tquery run\n", time.Now().UnixNano())
+		}
+	},
+}
+
+var queryRmCmd = &cobra.Command{
+	Use:   "rm NAME",
+	Short: "Delete a saved query",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		QuietOpt, _ = cmd.Flags().GetBool("quiet")
+		VerboseOpt, _ = cmd.Flags().GetBool("verbose")
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tSTART\tquery rm\n", time.Now().UnixNano())
+		}
+		path, err := queryLibrary().Remove(args[0])
+		exitOnError(err)
+		if !QuietOpt {
+			fmt.Fprintf(os.Stderr, "Deleted %s\n", path)
+		}
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tEND\tquery rm\n", time.Now().UnixNano())
+		}
+	},
+}
+
+// userQueryDir returns the query directory in the user config directory.
+func userQueryDir() string {
+	if dir := viper.GetString("query_dir"); len(dir) > 0 {
+		return dir
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "sumo-search-job-cli", "queries")
+}
+
+// projectQueryDir returns the nearest project query directory.
+func projectQueryDir() (string, bool) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", false
+	}
+	return queries.FindProjectDir(cwd)
+}
+
+// queryLibrary returns the library of the project and user query
+// directories, in lookup order.
+func queryLibrary() *queries.Library {
+	var dirs []string
+	if dir, ok := projectQueryDir(); ok {
+		dirs = append(dirs, dir)
+	}
+	if dir := userQueryDir(); len(dir) > 0 {
+		dirs = append(dirs, dir)
+	}
+	return queries.Open(dirs...)
+}
+
+// querySaveDir returns the directory that 'query save' writes to.
+func querySaveDir() (string, error) {
+	if QueryProjectOpt {
+		if dir, ok := projectQueryDir(); ok {
+			return dir, nil
+		}
+		return queries.ProjectDir, nil
+	}
+	if !QueryGlobalOpt {
+		if dir, ok := projectQueryDir(); ok {
+			return dir, nil
+		}
+	}
+	if dir := userQueryDir(); len(dir) > 0 {
+		return dir, nil
+	}
+	return "", fmt.Errorf("no user config directory; set query_dir in the config file")
+}
+
+func executeQuerySave(cmd *cobra.Command, args []string) error {
+	if VerboseOpt {
+		fmt.Fprintf(os.Stderr, "%d\tSTART\tquery::executeQuerySave()\n", time.Now().UnixNano())
+	}
+	flagLayer, err := jobFlagLayer(cmd)
+	if err != nil {
+		return err
+	}
+	layer := flagLayer.layer
+	if layer.Query == nil {
+		return fmt.Errorf("a query is required (--query or --query-file)")
+	}
+	if layer.From != nil && layer.To != nil && layer.Duration != nil {
+		return fmt.Errorf("from, to and duration can't all be given")
+	}
+	if layer.AutoParsingMode != nil && !strings.Contains(*layer.AutoParsingMode, "{{") {
+		if err := validateAutoParsingMode(*layer.AutoParsingMode); err != nil {
+			return err
+		}
+	}
+	vars, err := templateVars()
+	if err != nil {
+		return err
+	}
+	query := queries.Query{
+		Name:            args[0],
+		Description:     QueryDescriptionOpt,
+		Query:           *layer.Query,
+		From:            deref(layer.From),
+		To:              deref(layer.To),
+		Duration:        deref(layer.Duration),
+		TimeZone:        deref(layer.Timezone),
+		ByReceiptTime:   layer.ByReceiptTime,
+		AutoParsingMode: deref(layer.AutoParsingMode),
+	}
+	if len(vars) > 0 {
+		query.Vars = vars
+	}
+	dir, err := querySaveDir()
+	if err != nil {
+		return err
+	}
+	path, err := queryLibrary().Save(dir, query, QueryForceOpt)
+	if err != nil {
+		return err
+	}
+	if !QuietOpt {
+		fmt.Fprintf(os.Stderr, "Saved %s\n", path)
+	}
+	if VerboseOpt {
+		fmt.Fprintf(os.Stderr, "%d\tEND\tquery::executeQuerySave()\n", time.Now().UnixNano())
+	}
+	return nil
+}
+
+func executeQueryList(cmd *cobra.Command, args []string) error {
+	list, err := queryLibrary().List()
+	if len(list) == 0 && err == nil {
+		if !QuietOpt {
+			fmt.Fprintf(os.Stderr, "No saved queries\n")
+		}
+		return nil
+	}
+	project, _ := projectQueryDir()
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "NAME\tSCOPE\tWINDOW\tDESCRIPTION")
+	for _, query := range list {
+		scope := "user"
+		if len(project) > 0 && filepath.Dir(query.Path) == project {
+			scope = "project"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", query.Name, scope, queryWindow(query), query.Description)
+	}
+	if flushErr := table.Flush(); flushErr != nil {
+		return flushErr
+	}
+	return err
+}
+
+// queryWindow summarizes the default time window of a saved query.
+func queryWindow(query queries.Query) string {
+	var parts []string
+	if len(query.From) > 0 {
+		parts = append(parts, "from "+query.From)
+	}
+	if len(query.To) > 0 {
+		parts = append(parts, "to "+query.To)
+	}
+	if len(query.Duration) > 0 {
+		parts = append(parts, "last "+query.Duration)
+		if len(query.From) > 0 || len(query.To) > 0 {
+			parts[len(parts)-1] = "for " + query.Duration
+		}
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, " ")
+}
+
+func executeQueryShow(cmd *cobra.Command, args []string) error {
+	query, err := queryLibrary().Load(args[0])
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(query.Path)
+	if err != nil {
+		return err
+	}
+	if !QuietOpt {
+		fmt.Fprintf(os.Stderr, "# %s\n", query.Path)
+	}
+	_, err = os.Stdout.Write(content)
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(queryCmd)
+	queryCmd.AddCommand(querySaveCmd, queryListCmd, queryShowCmd, queryRunCmd, queryRmCmd)
+
+	querySaveCmd.Flags().StringVarP(&QueryOpt, "query", "q", "", "Search query")
+	querySaveCmd.Flags().StringVarP(&QueryFileOpt, "query-file", "Q", "", "Path to file with search query")
+	querySaveCmd.Flags().StringVarP(&DurationOpt, "duration", "d", "", "Default size of time span (e.g. 3h, 1d)")
+	querySaveCmd.Flags().StringVarP(&FromTimeOpt, "from", "f", "", "Default search window start time (e.g. -15m, now-1d@d, yesterday)")
+	querySaveCmd.Flags().StringVarP(&ToTimeOpt, "to", "t", "", "Default search window end time (same forms as --from)")
+	querySaveCmd.Flags().StringVarP(&TimeZoneOpt, "timezone", "z", "", "Default timezone for the search window")
+	querySaveCmd.Flags().BoolP("by-receipt-time", "b", false, "Use receipt-time instead of log message timestamps")
+	querySaveCmd.Flags().StringVarP(&AutoParsingModeOpt, "auto-parse", "A", "", "Default auto-parsing mode ("+strings.Join(AutoParsingModes, ", ")+")")
+	querySaveCmd.Flags().StringArrayVar(&VarOpts, "var", nil, "Set a default template variable as KEY=VALUE (repeatable)")
+	querySaveCmd.Flags().StringVar(&VarsFileOpt, "vars-file", "", "YAML or JSON file of default template variables")
+	querySaveCmd.Flags().StringVar(&QueryDescriptionOpt, "description", "", "Description shown by 'query list'")
+	querySaveCmd.Flags().BoolVar(&QueryForceOpt, "force", false, "Replace an existing query of the same name")
+	querySaveCmd.Flags().BoolVar(&QueryGlobalOpt, "global", false, "Save to the user query directory even inside a project")
+	querySaveCmd.Flags().BoolVar(&QueryProjectOpt, "project", false, "Save to the project query directory, creating "+queries.ProjectDir+" in the current directory if there is none")
+}
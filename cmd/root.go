@@ -1,17 +1,37 @@
 package cmd
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"github.com/nhoag/sumo-search-job-cli/client"
+	"github.com/nhoag/sumo-search-job-cli/registry"
 )
 
 var (
 	cfgFile       string
+	ProfileOpt    string
 	DeploymentOpt string
+	EndpointOpt   string
 	QuietOpt      bool
 	VerboseOpt    bool
+
+	apiClient *client.Client
+	// apiClientMu guards the creation of apiClient, which concurrent split
+	// and sweep jobs may request at once.
+	apiClientMu sync.Mutex
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -19,24 +39,62 @@ var rootCmd = &cobra.Command{
 	Use:   "sumo-search-job-cli",
 	Short: "Sumo Logic Search Job CLI",
 	Long:  `Command line interface to the Sumo Logic Search Job API.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(applyProfile(cmd))
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	err := rootCmd.Execute()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// The first Ctrl-C or SIGTERM cancels in-flight requests so that jobs can
+	// be cleaned up; a second one terminates immediately.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		signal.Stop(signals)
+		close(interrupted)
+		cancel()
+	}()
+
+	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
 		os.Exit(1)
 	}
+	if isInterrupted() {
+		exitOnError(ctx.Err())
+	}
 }
 
 func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.sumo-search-job-cli.yaml)")
-	rootCmd.PersistentFlags().StringVar(&DeploymentOpt, "deployment", "us1", "Deployment of Sumo Logic instance (au, ca, de, eu, fed, in, jp, us1, us2)")
+	rootCmd.PersistentFlags().StringVar(&ProfileOpt, "profile", "", "Named profile from the profiles section of the config file (default is $SUMO_PROFILE, then the profile config key)")
+	viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
+	viper.BindEnv("profile", "SUMO_PROFILE")
+	rootCmd.PersistentFlags().StringVar(&DeploymentOpt, "deployment", "", "Deployment of Sumo Logic instance ("+strings.Join(client.DeploymentNames(), ", ")+") (default us1)")
 	viper.BindPFlag("deployment", rootCmd.PersistentFlags().Lookup("deployment"))
+	rootCmd.PersistentFlags().StringVar(&EndpointOpt, "endpoint", "", "Full API endpoint URL, overriding deployment (e.g. http://localhost:8080/api)")
+	viper.BindPFlag("endpoint", rootCmd.PersistentFlags().Lookup("endpoint"))
 	rootCmd.PersistentFlags().BoolP("quiet", "S", false, "Don't display status updates")
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Display verbose information")
+	rootCmd.PersistentFlags().Int("retry-max-attempts", client.DefaultRetryPolicy.MaxAttempts, "Maximum attempts per API call on 429 and 5xx responses (1 disables retries)")
+	viper.BindPFlag("retry.max_attempts", rootCmd.PersistentFlags().Lookup("retry-max-attempts"))
+	rootCmd.PersistentFlags().Duration("retry-base-delay", client.DefaultRetryPolicy.BaseDelay, "Delay before the first retry, doubled on each further retry")
+	viper.BindPFlag("retry.base_delay", rootCmd.PersistentFlags().Lookup("retry-base-delay"))
+	rootCmd.PersistentFlags().Duration("retry-max-delay", client.DefaultRetryPolicy.MaxDelay, "Upper bound for retry delays, including Retry-After")
+	viper.BindPFlag("retry.max_delay", rootCmd.PersistentFlags().Lookup("retry-max-delay"))
+	rootCmd.PersistentFlags().Float64("retry-jitter", client.DefaultRetryPolicy.Jitter, "Fraction of each retry delay to randomize (0-1)")
+	viper.BindPFlag("retry.jitter", rootCmd.PersistentFlags().Lookup("retry-jitter"))
+	rootCmd.PersistentFlags().Float64("rate-limit", client.DefaultRateLimit.Rate, "Maximum API requests per second, shared by all sumo processes using the same access key (0 disables)")
+	viper.BindPFlag("rate_limit.rate", rootCmd.PersistentFlags().Lookup("rate-limit"))
+	rootCmd.PersistentFlags().Int("rate-burst", client.DefaultRateLimit.Burst, "Maximum burst of API requests")
+	viper.BindPFlag("rate_limit.burst", rootCmd.PersistentFlags().Lookup("rate-burst"))
+	rootCmd.PersistentFlags().String("rate-state-file", "", "File used to share the rate limit between processes (default is per access key in the user cache dir)")
+	viper.BindPFlag("rate_limit.state_file", rootCmd.PersistentFlags().Lookup("rate-state-file"))
 }
 
 func initConfig() {
@@ -61,3 +119,93 @@ func initConfig() {
 		// React to config file read success here
 	}
 }
+
+// getClient returns the Search Job API client for this run, building it from
+// the loaded configuration on first use.
+func getClient() *client.Client {
+	apiClientMu.Lock()
+	defer apiClientMu.Unlock()
+	if apiClient == nil {
+		creds, err := resolveCredentials(rootCmd.Context())
+		cobra.CheckErr(err)
+		apiClient, err = client.New(client.Config{
+			AccessID:    creds.AccessID,
+			AccessKey:   creds.AccessKey,
+			Endpoint:    viper.GetString("endpoint"),
+			Deployment:  viper.GetString("deployment"),
+			DefaultHost: viper.GetString("default_host"),
+			Retry: &client.RetryPolicy{
+				MaxAttempts: viper.GetInt("retry.max_attempts"),
+				BaseDelay:   viper.GetDuration("retry.base_delay"),
+				MaxDelay:    viper.GetDuration("retry.max_delay"),
+				Jitter:      viper.GetFloat64("retry.jitter"),
+				OnRetry:     logRetry,
+			},
+			RateLimit: &client.RateLimit{
+				Rate:      viper.GetFloat64("rate_limit.rate"),
+				Burst:     viper.GetInt("rate_limit.burst"),
+				StateFile: rateStateFile(creds.AccessID),
+			},
+			CookieDir:  cookieDir(),
+			OnRedirect: logRedirect,
+		})
+		cobra.CheckErr(err)
+	}
+	return apiClient
+}
+
+func logRedirect(endpoint string, deployment string) {
+	if QuietOpt {
+		return
+	}
+	if len(deployment) > 0 {
+		fmt.Fprintf(os.Stderr, "API redirected to %s; this account is on the %s deployment (use --deployment %s)\n", endpoint, deployment, deployment)
+	} else {
+		fmt.Fprintf(os.Stderr, "API redirected to %s\n", endpoint)
+	}
+}
+
+// cookieDir returns the directory holding the saved cookies of search jobs.
+func cookieDir() string {
+	if dir := viper.GetString("cookie_dir"); len(dir) > 0 {
+		return dir
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(cacheDir, "sumo-search-job-cli", "cookies")
+}
+
+// getRegistry returns the local registry of created search jobs, or nil when
+// no location for it can be determined.
+func getRegistry() *registry.Registry {
+	if path := viper.GetString("registry_file"); len(path) > 0 {
+		return registry.Open(path)
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return nil
+	}
+	return registry.Open(filepath.Join(configDir, "sumo-search-job-cli", "jobs.json"))
+}
+
+// rateStateFile returns the shared rate limit state file. By default one file
+// exists per access key, matching how Sumo Logic enforces its limits.
+func rateStateFile(accessID string) string {
+	if stateFile := viper.GetString("rate_limit.state_file"); len(stateFile) > 0 {
+		return stateFile
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(accessID))
+	return filepath.Join(cacheDir, "sumo-search-job-cli", "ratelimit-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+func logRetry(op string, attempt int, delay time.Duration, err error) {
+	if !QuietOpt {
+		fmt.Fprintf(os.Stderr, "%s failed (attempt %d): %v; retrying in %s\n", op, attempt, err, delay.Round(time.Millisecond))
+	}
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	openapi "github.com/nhoag/sumologic-search-job-client-go"
+)
+
+// jobProgress is called with every polled status of a search job. It stops
+// waiting for the job early by returning true.
+type jobProgress func(status *openapi.SearchJobState) (bool, error)
+
+// waitJob polls a search job until it is done, or until progress, when
+// given, stops it. It fails when the job reports errors or is cancelled,
+// and when it times out or stalls as configured by the polling flags. It
+// returns the last polled status.
+func waitJob(ctx context.Context, jobId string, progress jobProgress) (*openapi.SearchJobState, error) {
+	poller := newJobPoller(jobId)
+	for {
+		status, err := getClient().GetSearchJobStatus(ctx, jobId)
+		if err != nil {
+			return nil, err
+		}
+		poller.report(status)
+		if err := poller.pendingError(); err != nil {
+			return nil, err
+		}
+		if status.GetState() == "CANCELLED" {
+			return nil, fmt.Errorf("search job %s was cancelled", jobId)
+		}
+		if progress != nil {
+			if stop, err := progress(status); err != nil || stop {
+				return status, err
+			}
+		}
+		if jobDone(status) {
+			return status, nil
+		}
+		if err := poller.observe(status); err != nil {
+			return nil, err
+		}
+		if err := poller.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// runJob creates a search job for jobDef, waits for it with waitJob, passes
+// its last status to fetch and deletes it. Interrupted jobs are left for
+// cleanupJobs, which honors --keep-on-interrupt.
+func runJob(ctx context.Context, jobDef JobDefinition, progress jobProgress, fetch func(jobId string, status *openapi.SearchJobState) error) error {
+	_, jobId, err := executeSearchJob(ctx, jobDef)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := deleteJob(ctx, jobId); err != nil && !QuietOpt {
+			fmt.Fprintf(os.Stderr, "Unable to delete search job %s: %v\n", jobId, err)
+		}
+	}()
+
+	status, err := waitJob(ctx, jobId, progress)
+	if err != nil {
+		return err
+	}
+	return fetch(jobId, status)
+}
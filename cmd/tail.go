@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	openapi "github.com/nhoag/sumologic-search-job-client-go"
+
+	"github.com/nhoag/sumo-search-job-cli/client"
+	"github.com/nhoag/sumo-search-job-cli/timeexpr"
+)
+
+var (
+	TailLagOpt      time.Duration
+	TailWindowOpt   time.Duration
+	TailIntervalOpt time.Duration
+	TailOutputOpt   string
+)
+
+// tailCmd represents the tail command
+var tailCmd = &cobra.Command{
+	Use:   "tail",
+	Short: "Follow new messages matching a query, like tail -f",
+	Long: `The tail command repeatedly searches a sliding window that ends at now
+	minus --lag, to give messages time to be ingested. Every --interval it
+	creates a search job for the last --window, prints the messages it hasn't
+	printed before and deletes the job. Overlapping windows catch messages
+	that are ingested late; duplicates are recognized by _messageid, _raw and
+	_messagetime. Stop it with Ctrl-C.`,
+	Args:   cobra.NoArgs,
+	PreRun: preparePolling,
+	Run: func(cmd *cobra.Command, args []string) {
+		QuietOpt, _ = cmd.Flags().GetBool("quiet")
+		VerboseOpt, _ = cmd.Flags().GetBool("verbose")
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tSTART\ttail\n", time.Now().UnixNano())
+		}
+		validateTail()
+		exitOnError(executeTail(cmd, args))
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tEND\ttail\n", time.Now().UnixNano())
+		}
+	},
+}
+
+func validateTail() {
+	if VerboseOpt {
+		fmt.Fprintf(os.Stderr, "%d\tSTART\ttail::validateTail()\n", time.Now().UnixNano())
+	}
+	cobra.CheckErr(validateOutputFormat(TailOutputOpt))
+	cobra.CheckErr(validateAutoParsingMode(AutoParsingModeOpt))
+	validatePolling()
+	validatePaging()
+	if TailLagOpt < 0 {
+		cobra.CheckErr(fmt.Errorf("lag can't be negative"))
+	}
+	if TailIntervalOpt <= 0 {
+		cobra.CheckErr(fmt.Errorf("interval must be positive"))
+	}
+	if TailWindowOpt < TailIntervalOpt {
+		cobra.CheckErr(fmt.Errorf("window must be at least the interval, or messages would be missed"))
+	}
+	if VerboseOpt {
+		fmt.Fprintf(os.Stderr, "%d\tEND\ttail::validateTail()\n", time.Now().UnixNano())
+	}
+}
+
+// tailSeen remembers the messages printed by tail, with the window in which
+// each was last found.
+type tailSeen map[[sha256.Size]byte]int
+
+func messageKey(row client.Row) [sha256.Size]byte {
+	return sha256.Sum256([]byte(row["_messageid"] + "\x00" + row["_raw"] + "\x00" + row["_messagetime"]))
+}
+
+func executeTail(cmd *cobra.Command, args []string) error {
+	if VerboseOpt {
+		fmt.Fprintf(os.Stderr, "%d\tSTART\ttail::executeTail()\n", time.Now().UnixNano())
+	}
+	ctx := cmd.Context()
+	query := QueryOpt
+	if len(QueryFileOpt) > 0 {
+		content, err := os.ReadFile(QueryFileOpt)
+		if err != nil {
+			return err
+		}
+		query = string(content)
+	}
+	if len(strings.TrimSpace(query)) == 0 {
+		return fmt.Errorf("a query is required (--query or --query-file)")
+	}
+	byReceiptTime, _ := cmd.Flags().GetBool("by-receipt-time")
+	writer, err := newResultWriter(os.Stdout, TailOutputOpt)
+	if err != nil {
+		return err
+	}
+
+	// A message can only reappear in the windows that overlap the one it
+	// was found in.
+	overlapping := int((TailWindowOpt+TailIntervalOpt-1)/TailIntervalOpt) + 1
+	seen := make(tailSeen)
+	var previousTo time.Time
+	for window := 0; ; window++ {
+		started := time.Now()
+		to := started.Add(-TailLagOpt).UTC().Truncate(time.Second)
+		from := to.Add(-TailWindowOpt)
+		// Searches slower than the interval must not leave gaps.
+		if !previousTo.IsZero() && previousTo.Before(from) {
+			from = previousTo
+		}
+		jobDef := JobDefinition{
+			Query:           query,
+			From:            from.Format(timeexpr.Layout),
+			To:              to.Format(timeexpr.Layout),
+			Timezone:        "UTC",
+			ByReceiptTime:   byReceiptTime,
+			AutoParsingMode: AutoParsingModeOpt,
+		}
+		fields, rows, err := runTailWindow(ctx, jobDef)
+		if isInterrupted() {
+			return writer.EndSection()
+		}
+		if err != nil {
+			return err
+		}
+		previousTo = to
+
+		var fresh []client.Row
+		for _, row := range rows {
+			key := messageKey(row)
+			if _, ok := seen[key]; !ok {
+				fresh = append(fresh, row)
+			}
+			seen[key] = window
+		}
+		for key, last := range seen {
+			if last < window-overlapping {
+				delete(seen, key)
+			}
+		}
+		sort.SliceStable(fresh, func(i, j int) bool {
+			return messageTime(fresh[i]) < messageTime(fresh[j])
+		})
+		if len(fresh) > 0 {
+			if err := writer.WritePage(fields, fresh); err != nil {
+				return err
+			}
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+		}
+		if VerboseOpt {
+			fmt.Fprintf(os.Stderr, "%d\tTAIL WINDOW:\t%s - %s\t%d messages, %d new\n", time.Now().UnixNano(), jobDef.From, jobDef.To, len(rows), len(fresh))
+		}
+
+		if err := sleep(ctx, time.Until(started.Add(TailIntervalOpt))); err != nil {
+			if isInterrupted() {
+				return writer.EndSection()
+			}
+			return err
+		}
+	}
+}
+
+// runTailWindow runs a search job for one window and returns its messages.
+func runTailWindow(ctx context.Context, jobDef JobDefinition) ([]client.Field, []client.Row, error) {
+	var fields []client.Field
+	var rows []client.Row
+	err := runJob(ctx, jobDef, nil, func(jobId string, status *openapi.SearchJobState) error {
+		var err error
+		fields, rows, err = collectPages(ctx, messagePages(jobId), status.GetMessageCount())
+		return err
+	})
+	return fields, rows, err
+}
+
+func init() {
+	rootCmd.AddCommand(tailCmd)
+
+	tailCmd.Flags().StringVarP(&QueryOpt, "query", "q", "", "Search query")
+	tailCmd.Flags().StringVarP(&QueryFileOpt, "query-file", "Q", "", "Path to file with search query")
+	tailCmd.Flags().BoolP("by-receipt-time", "b", false, "Use receipt-time instead of log message timestamps")
+	tailCmd.Flags().StringVarP(&AutoParsingModeOpt, "auto-parse", "A", "", "Specify auto-parsing mode to use ('intelligent' automatically runs field extraction rules)")
+	tailCmd.Flags().DurationVar(&TailLagOpt, "lag", time.Minute, "End each window this long before now, to allow for ingestion delay")
+	tailCmd.Flags().DurationVar(&TailWindowOpt, "window", 2*time.Minute, "Length of each search window; windows overlap by window minus interval")
+	tailCmd.Flags().DurationVar(&TailIntervalOpt, "interval", 15*time.Second, "Time between searches")
+	tailCmd.Flags().Int32VarP(&LimitOpt, "limit", "l", 0, "Specify pagination limit, up to 10000 (default chooses the page size)")
+	tailCmd.Flags().StringVarP(&TailOutputOpt, "output", "O", "ndjson", "Output format ("+strings.Join(OutputFormats, ", ")+")")
+	addPollFlags(tailCmd.Flags())
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"go.yaml.in/yaml/v3"
+)
+
+var (
+	VarOpts     []string
+	VarsFileOpt string
+)
+
+// templateVars returns the template variables from --vars-file, overridden
+// by --var. The defaults of a saved query rank lowest.
+func templateVars() (map[string]string, error) {
+	vars := make(map[string]string)
+	if savedQuery != nil {
+		for key, value := range savedQuery.Vars {
+			vars[key] = value
+		}
+	}
+	if len(VarsFileOpt) > 0 {
+		content, err := os.ReadFile(VarsFileOpt)
+		if err != nil {
+			return nil, err
+		}
+		// JSON is valid YAML, so either format works.
+		var values map[string]interface{}
+		if err := yaml.Unmarshal(content, &values); err != nil {
+			return nil, fmt.Errorf("%s: %w", VarsFileOpt, err)
+		}
+		for key, value := range values {
+			switch value.(type) {
+			case map[string]interface{}, []interface{}:
+				return nil, fmt.Errorf("%s: variable %s must be a scalar", VarsFileOpt, key)
+			case nil:
+				vars[key] = ""
+			default:
+				vars[key] = fmt.Sprint(value)
+			}
+		}
+	}
+	for _, opt := range VarOpts {
+		key, value, ok := strings.Cut(opt, "=")
+		if !ok || len(key) == 0 {
+			return nil, fmt.Errorf("invalid var %q: expected KEY=VALUE", opt)
+		}
+		vars[key] = value
+	}
+	return vars, nil
+}
+
+// renderTemplate expands the text/template placeholders of text, such as
+// {{.service}}, with vars. Referencing an undefined variable is an error.
+func renderTemplate(name string, text string, vars map[string]string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, vars); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
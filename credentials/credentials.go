@@ -0,0 +1,37 @@
+// Package credentials resolves Sumo Logic access keys from sources other than
+// plaintext configuration: an external credential process, the desktop
+// keyring, or a passphrase-encrypted file.
+package credentials
+
+import (
+	"context"
+	"errors"
+)
+
+// Credentials is a Sumo Logic access ID and access key pair.
+type Credentials struct {
+	AccessID  string `json:"accessId"`
+	AccessKey string `json:"accessKey"`
+}
+
+// ErrNotFound is returned by providers that hold no credentials for the
+// requested profile.
+var ErrNotFound = errors.New("credentials not found")
+
+// Provider retrieves credentials.
+type Provider interface {
+	Retrieve(ctx context.Context) (Credentials, error)
+}
+
+// Store is a provider that can also save credentials.
+type Store interface {
+	Provider
+	Save(ctx context.Context, creds Credentials) error
+}
+
+func (c Credentials) validate() error {
+	if len(c.AccessID) == 0 || len(c.AccessKey) == 0 {
+		return errors.New("credentials must contain an accessId and an accessKey")
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package credentials
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// scrypt parameters for deriving the file key from the passphrase.
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	fileVersion  = 1
+	fileKeyBytes = 32
+)
+
+// encryptedFile is the on-disk format of a credentials file. Data is the
+// AES-256-GCM encrypted JSON object of credentials keyed by profile.
+type encryptedFile struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// File stores the credentials of every profile in one file, encrypted with a
+// key derived from a passphrase.
+type File struct {
+	Path    string
+	Profile string
+	// Passphrase is called when the file has to be decrypted or encrypted.
+	Passphrase func() ([]byte, error)
+	// NewPassphrase, if set, is called instead of Passphrase when Save
+	// creates the file, e.g. to have the passphrase entered twice.
+	NewPassphrase func() ([]byte, error)
+}
+
+// Retrieve decrypts the file and returns the profile's credentials.
+func (f File) Retrieve(ctx context.Context) (Credentials, error) {
+	profiles, err := f.read()
+	if errors.Is(err, os.ErrNotExist) {
+		return Credentials{}, fmt.Errorf("credentials file %s: %w", f.Path, ErrNotFound)
+	}
+	if err != nil {
+		return Credentials{}, err
+	}
+	creds, ok := profiles[f.Profile]
+	if !ok {
+		return Credentials{}, fmt.Errorf("credentials file %s, profile %s: %w", f.Path, f.Profile, ErrNotFound)
+	}
+	return creds, nil
+}
+
+// Save stores the profile's credentials, keeping those of other profiles.
+// An existing file must be unlocked with the same passphrase.
+func (f File) Save(ctx context.Context, creds Credentials) error {
+	if err := creds.validate(); err != nil {
+		return err
+	}
+	getPassphrase := f.Passphrase
+	if _, err := os.Stat(f.Path); errors.Is(err, os.ErrNotExist) && f.NewPassphrase != nil {
+		getPassphrase = f.NewPassphrase
+	}
+	passphrase, err := getPassphrase()
+	if err != nil {
+		return err
+	}
+	f.Passphrase = func() ([]byte, error) { return passphrase, nil }
+
+	profiles, err := f.read()
+	if errors.Is(err, os.ErrNotExist) {
+		profiles = make(map[string]Credentials)
+	} else if err != nil {
+		return err
+	}
+	profiles[f.Profile] = creds
+
+	plaintext, err := json.Marshal(profiles)
+	if err != nil {
+		return err
+	}
+	file := encryptedFile{Version: fileVersion, Salt: make([]byte, 16)}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return err
+	}
+	aead, err := fileCipher(passphrase, file.Salt)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+	file.Data = aead.Seal(nil, file.Nonce, plaintext, nil)
+	content, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), ".credentials-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(content, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.Path)
+}
+
+// read decrypts the file.
+func (f File) read() (map[string]Credentials, error) {
+	content, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	var file encryptedFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("credentials file %s: %w", f.Path, err)
+	}
+	if file.Version != fileVersion {
+		return nil, fmt.Errorf("credentials file %s: unsupported version %d", f.Path, file.Version)
+	}
+	passphrase, err := f.Passphrase()
+	if err != nil {
+		return nil, err
+	}
+	aead, err := fileCipher(passphrase, file.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("credentials file %s: wrong passphrase or corrupt file", f.Path)
+	}
+	var profiles map[string]Credentials
+	if err := json.Unmarshal(plaintext, &profiles); err != nil {
+		return nil, fmt.Errorf("credentials file %s: %w", f.Path, err)
+	}
+	return profiles, nil
+}
+
+func fileCipher(passphrase []byte, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, fileKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,199 @@
+package credentials_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nhoag/sumo-search-job-cli/credentials"
+)
+
+// fileStore returns a credentials file store for profile that is unlocked
+// with passphrase.
+func fileStore(path string, profile string, passphrase string) credentials.File {
+	return credentials.File{
+		Path:       path,
+		Profile:    profile,
+		Passphrase: func() ([]byte, error) { return []byte(passphrase), nil },
+	}
+}
+
+func TestFileRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sumo", "credentials.json")
+	prod := credentials.Credentials{AccessID: "prodId", AccessKey: "prodKey"}
+	dev := credentials.Credentials{AccessID: "devId", AccessKey: "devKey"}
+	if err := fileStore(path, "prod", "secret").Save(ctx, prod); err != nil {
+		t.Fatal(err)
+	}
+	if err := fileStore(path, "dev", "secret").Save(ctx, dev); err != nil {
+		t.Fatal(err)
+	}
+	for profile, want := range map[string]credentials.Credentials{"prod": prod, "dev": dev} {
+		got, err := fileStore(path, profile, "secret").Retrieve(ctx)
+		if err != nil {
+			t.Fatalf("profile %s: %v", profile, err)
+		}
+		if got != want {
+			t.Errorf("profile %s: got %+v, want %+v", profile, got, want)
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"prodKey", "devKey"} {
+		if strings.Contains(string(content), secret) {
+			t.Errorf("credentials file contains %s in plaintext", secret)
+		}
+	}
+	info, err := os.Stat(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0700 {
+		t.Errorf("credentials directory mode %o, want 700", perm)
+	}
+}
+
+func TestFileNotFound(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if _, err := fileStore(path, "prod", "secret").Retrieve(ctx); !errors.Is(err, credentials.ErrNotFound) {
+		t.Errorf("missing file: error %v, want %v", err, credentials.ErrNotFound)
+	}
+	if err := fileStore(path, "prod", "secret").Save(ctx, credentials.Credentials{AccessID: "id", AccessKey: "key"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fileStore(path, "dev", "secret").Retrieve(ctx); !errors.Is(err, credentials.ErrNotFound) {
+		t.Errorf("missing profile: error %v, want %v", err, credentials.ErrNotFound)
+	}
+}
+
+func TestFileWrongPassphrase(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	creds := credentials.Credentials{AccessID: "id", AccessKey: "key"}
+	if err := fileStore(path, "prod", "secret").Save(ctx, creds); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fileStore(path, "prod", "guess").Retrieve(ctx); err == nil || !strings.Contains(err.Error(), "wrong passphrase or corrupt file") {
+		t.Errorf("Retrieve() error = %v, want wrong passphrase", err)
+	}
+	// Saving with another passphrase must not replace the file.
+	if err := fileStore(path, "dev", "guess").Save(ctx, creds); err == nil || !strings.Contains(err.Error(), "wrong passphrase or corrupt file") {
+		t.Errorf("Save() error = %v, want wrong passphrase", err)
+	}
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Error("credentials file changed after a save with the wrong passphrase")
+	}
+}
+
+func TestFileCorrupt(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		corrupt func(file map[string]interface{})
+		err     string
+	}{
+		{"flipped ciphertext", func(file map[string]interface{}) {
+			data, _ := base64.StdEncoding.DecodeString(file["data"].(string))
+			data[0] ^= 1
+			file["data"] = base64.StdEncoding.EncodeToString(data)
+		}, "wrong passphrase or corrupt file"},
+		{"other nonce", func(file map[string]interface{}) {
+			file["nonce"] = "AAAAAAAAAAAAAAAA"
+		}, "wrong passphrase or corrupt file"},
+		{"unsupported version", func(file map[string]interface{}) {
+			file["version"] = 2
+		}, "unsupported version 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "credentials.json")
+			if err := fileStore(path, "prod", "secret").Save(ctx, credentials.Credentials{AccessID: "id", AccessKey: "key"}); err != nil {
+				t.Fatal(err)
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var file map[string]interface{}
+			if err := json.Unmarshal(content, &file); err != nil {
+				t.Fatal(err)
+			}
+			tt.corrupt(file)
+			content, err = json.Marshal(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, content, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := fileStore(path, "prod", "secret").Retrieve(ctx); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Retrieve() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fileStore(path, "prod", "secret").Retrieve(ctx); err == nil || errors.Is(err, credentials.ErrNotFound) {
+		t.Errorf("truncated file: error %v, want a parse error", err)
+	}
+}
+
+func TestFileNewPassphrase(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	var calls []string
+	store := credentials.File{
+		Path:    path,
+		Profile: "prod",
+		Passphrase: func() ([]byte, error) {
+			calls = append(calls, "passphrase")
+			return []byte("secret"), nil
+		},
+		NewPassphrase: func() ([]byte, error) {
+			calls = append(calls, "new")
+			return []byte("secret"), nil
+		},
+	}
+	creds := credentials.Credentials{AccessID: "id", AccessKey: "key"}
+	if err := store.Save(ctx, creds); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(ctx, creds); err != nil {
+		t.Fatal(err)
+	}
+	// Only the save that creates the file asks for a new passphrase.
+	if got := strings.Join(calls, ","); got != "new,passphrase" {
+		t.Errorf("passphrase calls %s, want new,passphrase", got)
+	}
+
+	store.NewPassphrase = func() ([]byte, error) { return nil, errors.New("the passphrases don't match") }
+	other := filepath.Join(t.TempDir(), "credentials.json")
+	store.Path = other
+	if err := store.Save(ctx, creds); err == nil {
+		t.Error("Save() succeeded without a confirmed passphrase")
+	}
+	if _, err := os.Stat(other); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("credentials file was created without a confirmed passphrase: %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// keyringService is the service attribute of stored secrets.
+const keyringService = "sumo-search-job-cli"
+
+// Keyring stores credentials in the desktop keyring through the Secret
+// Service API (GNOME Keyring, KWallet), using the secret-tool command from
+// libsecret. Each profile has its own entry.
+type Keyring struct {
+	Profile string
+}
+
+// Retrieve looks up the profile's credentials.
+func (k Keyring) Retrieve(ctx context.Context) (Credentials, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "secret-tool", "lookup", "service", keyringService, "profile", k.Profile)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() == 0 {
+			// secret-tool exits 1 without a message when nothing matches.
+			return Credentials{}, fmt.Errorf("keyring profile %s: %w", k.Profile, ErrNotFound)
+		}
+		return Credentials{}, fmt.Errorf("keyring: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	var creds Credentials
+	if err := json.Unmarshal(stdout.Bytes(), &creds); err != nil {
+		return Credentials{}, fmt.Errorf("keyring profile %s: %w", k.Profile, err)
+	}
+	if err := creds.validate(); err != nil {
+		return Credentials{}, fmt.Errorf("keyring profile %s: %w", k.Profile, err)
+	}
+	return creds, nil
+}
+
+// Save stores the profile's credentials, replacing any earlier entry.
+func (k Keyring) Save(ctx context.Context, creds Credentials) error {
+	if err := creds.validate(); err != nil {
+		return err
+	}
+	content, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "secret-tool", "store",
+		"--label", "Sumo Logic credentials ("+k.Profile+")",
+		"service", keyringService, "profile", k.Profile)
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("keyring: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// Process runs an external command that prints credentials as JSON, in the
+// manner of the AWS CLI's credential_process:
+//
+//	{"accessId": "...", "accessKey": "..."}
+//
+// The command is run by the shell. Its stderr and stdin are passed through so
+// that it can prompt the user.
+type Process struct {
+	Command string
+}
+
+// Retrieve runs the command and decodes its output.
+func (p Process) Retrieve(ctx context.Context) (Credentials, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", p.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", p.Command)
+	}
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+	if err := cmd.Run(); err != nil {
+		return Credentials{}, fmt.Errorf("credential_process: %w", err)
+	}
+	var creds Credentials
+	if err := json.Unmarshal(stdout.Bytes(), &creds); err != nil {
+		return Credentials{}, fmt.Errorf("credential_process: invalid output: %w", err)
+	}
+	if err := creds.validate(); err != nil {
+		return Credentials{}, fmt.Errorf("credential_process: %w", err)
+	}
+	return creds, nil
+}
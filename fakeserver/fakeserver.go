@@ -0,0 +1,432 @@
+// Package fakeserver implements an in-memory Sumo Logic Search Job API for
+// offline development and tests.
+package fakeserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLimit is the largest page size the API accepts.
+const maxLimit = 10000
+
+// Config configures a Server.
+type Config struct {
+	Fixture Fixture
+	// AccessID and AccessKey, if set, are required as basic auth credentials.
+	AccessID  string
+	AccessKey string
+	// JobTimeout is how long a job lives without requests. Defaults to five
+	// minutes, like the real API.
+	JobTimeout time.Duration
+}
+
+// Server is an http.Handler serving the Search Job API under /api/v1.
+type Server struct {
+	config Config
+
+	mu       sync.Mutex
+	nextId   int
+	jobs     map[string]*job
+	faults   []*Fault
+	requests map[string]int
+}
+
+// job is a search job held by the server.
+type job struct {
+	id         string
+	definition map[string]interface{}
+	scenario   Scenario
+	messages   []map[string]string
+	polls      int
+	state      string
+	lastSeen   time.Time
+}
+
+// New returns a server for the given configuration.
+func New(config Config) *Server {
+	if config.JobTimeout <= 0 {
+		config.JobTimeout = 5 * time.Minute
+	}
+	s := &Server{
+		config:   config,
+		jobs:     make(map[string]*job),
+		requests: make(map[string]int),
+	}
+	for i := range config.Fixture.Faults {
+		fault := config.Fixture.Faults[i]
+		s.faults = append(s.faults, &fault)
+	}
+	return s
+}
+
+// AddFault registers a fault in addition to those in the fixture.
+func (s *Server) AddFault(fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &fault)
+}
+
+// SetState forces the state of a job, e.g. to simulate a cancellation. It
+// reports whether the job exists.
+func (s *Server) SetState(jobId string, state string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[jobId]
+	if ok {
+		j.state = state
+	}
+	return ok
+}
+
+// Definition returns the request body a job was created with.
+func (s *Server) Definition(jobId string) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[jobId]
+	if !ok {
+		return nil, false
+	}
+	return j.definition, true
+}
+
+// JobIds returns the IDs of the live jobs.
+func (s *Server) JobIds() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire()
+	ids := make([]string, 0, len(s.jobs))
+	for id := range s.jobs {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Requests returns the number of requests made for op, e.g. create or
+// messages, including those answered with a fault.
+func (s *Server) Requests(op string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[op]
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if len(s.config.AccessID) > 0 {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != s.config.AccessID || pass != s.config.AccessKey {
+			writeError(w, http.StatusUnauthorized, "unauthorized", "Credential could not be verified.")
+			return
+		}
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/search/jobs")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case path == r.URL.Path:
+		writeError(w, http.StatusNotFound, "not.found", "Unknown path "+r.URL.Path)
+	case len(path) == 0 || path == "/":
+		s.handle(w, r, "create", "", http.MethodPost, s.create)
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		s.handle(w, r, "delete", parts[0], http.MethodDelete, s.delete)
+	case len(parts) == 1:
+		s.handle(w, r, "status", parts[0], http.MethodGet, s.status)
+	case len(parts) == 2 && parts[1] == "messages":
+		s.handle(w, r, "messages", parts[0], http.MethodGet, s.messages)
+	case len(parts) == 2 && parts[1] == "records":
+		s.handle(w, r, "records", parts[0], http.MethodGet, s.records)
+	case len(parts) == 2 && parts[1] == "state" && r.Method == http.MethodPut:
+		s.handle(w, r, "state", parts[0], http.MethodPut, s.setState)
+	default:
+		writeError(w, http.StatusNotFound, "not.found", "Unknown path "+r.URL.Path)
+	}
+}
+
+// handle applies faults, method checks and job lookup before calling fn.
+func (s *Server) handle(w http.ResponseWriter, r *http.Request, op string, jobId string, method string, fn func(http.ResponseWriter, *http.Request, *job)) {
+	if r.Method != method {
+		writeError(w, http.StatusMethodNotAllowed, "method.not.allowed", r.Method+" is not allowed")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[op]++
+	if fault := s.fault(op); fault != nil {
+		if len(fault.RetryAfter) > 0 {
+			w.Header().Set("Retry-After", fault.RetryAfter)
+		}
+		writeError(w, fault.Status, "fake.fault", fmt.Sprintf("Injected %d for %s", fault.Status, op))
+		return
+	}
+	s.expire()
+	var j *job
+	if len(jobId) > 0 {
+		j = s.jobs[jobId]
+		if j == nil {
+			writeError(w, http.StatusNotFound, "jobid.invalid", "Job ID is invalid.")
+			return
+		}
+		j.lastSeen = time.Now()
+	}
+	fn(w, r, j)
+}
+
+// fault returns the first active fault for op and counts it down. Exhausted
+// faults are marked with a negative count.
+func (s *Server) fault(op string) *Fault {
+	for _, fault := range s.faults {
+		if fault.Op != op && fault.Op != "*" {
+			continue
+		}
+		if fault.Count < 0 {
+			continue
+		}
+		if fault.After > 0 {
+			fault.After--
+			continue
+		}
+		if fault.Count > 0 {
+			fault.Count--
+			if fault.Count == 0 {
+				fault.Count = -1
+			}
+		}
+		return fault
+	}
+	return nil
+}
+
+// expire removes jobs that have not been touched within the job timeout.
+func (s *Server) expire() {
+	for id, j := range s.jobs {
+		if time.Since(j.lastSeen) > s.config.JobTimeout {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+func (s *Server) create(w http.ResponseWriter, r *http.Request, _ *job) {
+	var definition map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&definition); err != nil {
+		writeError(w, http.StatusBadRequest, "searchjob.invalid.body", err.Error())
+		return
+	}
+	query, _ := definition["query"].(string)
+	s.nextId++
+	j := &job{
+		id:         fmt.Sprintf("FAKE%012X", s.nextId),
+		definition: definition,
+		scenario:   s.config.Fixture.scenario(query),
+		lastSeen:   time.Now(),
+	}
+	j.messages = j.scenario.Messages
+	if j.scenario.ByTime {
+		from, to, err := timeRange(definition)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "searchjob.invalid.timerange", err.Error())
+			return
+		}
+		j.messages = nil
+		for _, message := range j.scenario.Messages {
+			t, _ := strconv.ParseInt(message["_messagetime"], 10, 64)
+			if t >= from && t < to {
+				j.messages = append(j.messages, message)
+			}
+		}
+	}
+	if limit := j.scenario.MessageLimit; limit > 0 && len(j.messages) > limit {
+		j.messages = j.messages[:limit]
+		j.state = "FORCE PAUSED"
+	}
+	s.jobs[j.id] = j
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	http.SetCookie(w, &http.Cookie{Name: "fakeserver-node", Value: j.id, Path: "/"})
+	w.Header().Set("Location", fmt.Sprintf("%s://%s/api/v1/search/jobs/%s", scheme, r.Host, j.id))
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"id":   j.id,
+		"link": map[string]string{"rel": "self", "href": w.Header().Get("Location")},
+	})
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request, j *job) {
+	delete(s.jobs, j.id)
+	writeJSON(w, http.StatusOK, map[string]string{"id": j.id})
+}
+
+func (s *Server) setState(w http.ResponseWriter, r *http.Request, j *job) {
+	var body struct {
+		State string `json:"state"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.State) == 0 {
+		writeError(w, http.StatusBadRequest, "state.invalid", "Expected {\"state\": \"...\"}")
+		return
+	}
+	j.state = body.State
+	writeJSON(w, http.StatusOK, map[string]string{"id": j.id, "state": j.state})
+}
+
+func (s *Server) status(w http.ResponseWriter, r *http.Request, j *job) {
+	state, progress := j.advance()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"state":            state,
+		"messageCount":     visible(len(j.messages), progress),
+		"recordCount":      visible(len(j.scenario.Records), progress),
+		"histogramBuckets": nonNil(j.scenario.HistogramBuckets),
+		"pendingErrors":    nonNil(j.scenario.PendingErrors),
+		"pendingWarnings":  nonNil(j.scenario.PendingWarnings),
+	})
+}
+
+func (s *Server) messages(w http.ResponseWriter, r *http.Request, j *job) {
+	offset, limit, ok := page(w, r)
+	if !ok {
+		return
+	}
+	_, progress := j.current()
+	items := window(j.messages, visible(len(j.messages), progress), offset, limit)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"fields":   nonNil(j.scenario.Fields),
+		"messages": items,
+	})
+}
+
+func (s *Server) records(w http.ResponseWriter, r *http.Request, j *job) {
+	offset, limit, ok := page(w, r)
+	if !ok {
+		return
+	}
+	_, progress := j.current()
+	items := window(j.scenario.Records, visible(len(j.scenario.Records), progress), offset, limit)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"fields":  nonNil(j.scenario.RecordFields),
+		"records": items,
+	})
+}
+
+// timeRange returns the from and to times of a job definition in epoch
+// milliseconds. They are either epoch milliseconds or local times in the
+// definition's time zone.
+func timeRange(definition map[string]interface{}) (int64, int64, error) {
+	location := time.UTC
+	if name, _ := definition["timeZone"].(string); len(name) > 0 {
+		var err error
+		if location, err = time.LoadLocation(name); err != nil {
+			return 0, 0, err
+		}
+	}
+	var bounds [2]int64
+	for i, key := range []string{"from", "to"} {
+		switch value := definition[key].(type) {
+		case float64:
+			bounds[i] = int64(value)
+		case string:
+			if millis, err := strconv.ParseInt(value, 10, 64); err == nil {
+				bounds[i] = millis
+				continue
+			}
+			t, err := time.ParseInLocation("2006-01-02T15:04:05", value, location)
+			if err != nil {
+				return 0, 0, fmt.Errorf("invalid %s time %q", key, value)
+			}
+			bounds[i] = t.UnixMilli()
+		default:
+			return 0, 0, fmt.Errorf("missing %s time", key)
+		}
+	}
+	return bounds[0], bounds[1], nil
+}
+
+// advance counts a status poll and returns the resulting state.
+func (j *job) advance() (string, float64) {
+	j.polls++
+	return j.current()
+}
+
+// current returns the job state and how far through its scenario it is, from
+// 0 to 1.
+func (j *job) current() (string, float64) {
+	steps := j.scenario.States
+	if len(steps) == 0 {
+		steps = []Step{{State: "DONE GATHERING RESULTS"}}
+	}
+	if len(j.state) > 0 {
+		return j.state, 1
+	}
+	polls := j.polls
+	for i, step := range steps {
+		length := step.Polls
+		if length < 1 {
+			length = 1
+		}
+		if i == len(steps)-1 || polls <= length {
+			if step.State == "DONE GATHERING RESULTS" || i == len(steps)-1 {
+				return step.State, 1
+			}
+			return step.State, float64(i+1) / float64(len(steps))
+		}
+		polls -= length
+	}
+	return steps[len(steps)-1].State, 1
+}
+
+func visible(total int, progress float64) int {
+	return int(float64(total) * progress)
+}
+
+// window returns the requested page of the visible items wrapped in the
+// {"map": ...} envelope the API uses.
+func window(items []map[string]string, visible int, offset int, limit int) []map[string]interface{} {
+	page := []map[string]interface{}{}
+	for i := offset; i < visible && i < offset+limit; i++ {
+		page = append(page, map[string]interface{}{"map": items[i]})
+	}
+	return page
+}
+
+// page parses the offset and limit query parameters.
+func page(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+	if err != nil || offset < 0 {
+		writeError(w, http.StatusBadRequest, "offset.invalid", "offset must be a non-negative integer")
+		return 0, 0, false
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 || limit > maxLimit {
+		writeError(w, http.StatusBadRequest, "limit.invalid", fmt.Sprintf("limit must be between 1 and %d", maxLimit))
+		return 0, 0, false
+	}
+	return offset, limit, true
+}
+
+// nonNil keeps empty lists as [] rather than null in responses.
+func nonNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError writes an error in the format used by the Search Job API.
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"status":  status,
+		"id":      "FAKE",
+		"code":    code,
+		"message": message,
+	})
+}
@@ -0,0 +1,205 @@
+package fakeserver_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nhoag/sumo-search-job-cli/client"
+	"github.com/nhoag/sumo-search-job-cli/fakeserver"
+)
+
+// testFixture has ten messages, revealed over two polls of gathering.
+func testFixture() fakeserver.Fixture {
+	scenario := fakeserver.Scenario{
+		States: []fakeserver.Step{
+			{State: "GATHERING RESULTS", Polls: 2},
+			{State: "DONE GATHERING RESULTS"},
+		},
+		Fields:       []fakeserver.Field{{Name: "_raw", FieldType: "string"}},
+		RecordFields: []fakeserver.Field{{Name: "_count", FieldType: "int"}},
+		Records:      []map[string]string{{"_count": "10"}},
+	}
+	for i := 0; i < 10; i++ {
+		scenario.Messages = append(scenario.Messages, map[string]string{"_raw": fmt.Sprintf("m%d", i)})
+	}
+	return fakeserver.Fixture{Scenarios: []fakeserver.Scenario{scenario}}
+}
+
+// newTestServer serves config and returns a client for it.
+func newTestServer(t *testing.T, config fakeserver.Config) (*fakeserver.Server, *client.Client) {
+	t.Helper()
+	server := fakeserver.New(config)
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+	c, err := client.New(client.Config{
+		AccessID:  config.AccessID,
+		AccessKey: config.AccessKey,
+		Endpoint:  srv.URL + "/api",
+		Retry:     &client.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+		RateLimit: &client.RateLimit{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, c
+}
+
+func TestSearchJobLifecycle(t *testing.T) {
+	ctx := context.Background()
+	server, c := newTestServer(t, fakeserver.Config{Fixture: testFixture()})
+
+	definition := client.SearchJobDefinition{Query: "error", From: "2024-01-01T00:00:00", To: "2024-01-01T01:00:00", TimeZone: "UTC"}
+	_, jobId, err := c.CreateSearchJob(ctx, definition)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := server.Definition(jobId); !ok || got["query"] != "error" {
+		t.Errorf("Definition(%s) = %v, %v", jobId, got, ok)
+	}
+
+	want := []struct {
+		state    string
+		messages int32
+	}{
+		{"GATHERING RESULTS", 5},
+		{"GATHERING RESULTS", 5},
+		{"DONE GATHERING RESULTS", 10},
+		{"DONE GATHERING RESULTS", 10},
+	}
+	for i, w := range want {
+		status, err := c.GetSearchJobStatus(ctx, jobId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.GetState() != w.state || status.GetMessageCount() != w.messages {
+			t.Errorf("poll %d: state %q with %d messages, want %q with %d", i+1, status.GetState(), status.GetMessageCount(), w.state, w.messages)
+		}
+	}
+
+	var raws []string
+	for offset := int32(0); offset < 10; offset += 4 {
+		page, err := c.GetSearchJobMessages(ctx, jobId, 4, offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Fields) != 1 || page.Fields[0].Name != "_raw" {
+			t.Errorf("fields = %v", page.Fields)
+		}
+		for _, row := range page.Rows() {
+			raws = append(raws, row["_raw"])
+		}
+	}
+	if fmt.Sprint(raws) != "[m0 m1 m2 m3 m4 m5 m6 m7 m8 m9]" {
+		t.Errorf("messages = %v", raws)
+	}
+	records, err := c.GetSearchJobRecords(ctx, jobId, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows := records.Rows(); len(rows) != 1 || rows[0]["_count"] != "10" {
+		t.Errorf("records = %v", rows)
+	}
+
+	if err := c.DeleteSearchJob(ctx, jobId); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetSearchJobStatus(ctx, jobId); !client.IsNotFound(err) {
+		t.Errorf("status after delete: err = %v, want 404", err)
+	}
+	if _, err := c.GetSearchJobMessages(ctx, jobId, 10, 0); !client.IsNotFound(err) {
+		t.Errorf("messages after delete: err = %v, want 404", err)
+	}
+	if err := c.DeleteSearchJob(ctx, jobId); !client.IsNotFound(err) {
+		t.Errorf("second delete: err = %v, want 404", err)
+	}
+}
+
+func TestPagingLimits(t *testing.T) {
+	ctx := context.Background()
+	server, c := newTestServer(t, fakeserver.Config{Fixture: testFixture()})
+	_, jobId, err := c.CreateSearchJob(ctx, client.SearchJobDefinition{Query: "*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetState(jobId, "DONE GATHERING RESULTS")
+
+	page, err := c.GetSearchJobMessages(ctx, jobId, 100, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(page.Rows()); got != 2 {
+		t.Errorf("rows from offset 8 = %d, want 2", got)
+	}
+	page, err = c.GetSearchJobMessages(ctx, jobId, 100, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(page.Rows()); got != 0 {
+		t.Errorf("rows past the end = %d, want 0", got)
+	}
+	if _, err := c.GetSearchJobMessages(ctx, jobId, 10001, 0); err == nil {
+		t.Error("limit above 10000 was accepted")
+	}
+}
+
+func TestFaults(t *testing.T) {
+	ctx := context.Background()
+	server, c := newTestServer(t, fakeserver.Config{Fixture: testFixture()})
+	_, jobId, err := c.CreateSearchJob(ctx, client.SearchJobDefinition{Query: "*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two failures are within the client's three attempts.
+	server.AddFault(fakeserver.Fault{Op: "status", Status: http.StatusTooManyRequests, Count: 2})
+	if _, err := c.GetSearchJobStatus(ctx, jobId); err != nil {
+		t.Errorf("status with two 429s: %v", err)
+	}
+	server.AddFault(fakeserver.Fault{Op: "messages", Status: http.StatusInternalServerError})
+	_, err = c.GetSearchJobMessages(ctx, jobId, 10, 0)
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("messages with a permanent 500: err = %v", err)
+	}
+}
+
+func TestJobTimeout(t *testing.T) {
+	ctx := context.Background()
+	server, c := newTestServer(t, fakeserver.Config{JobTimeout: 50 * time.Millisecond})
+	_, jobId, err := c.CreateSearchJob(ctx, client.SearchJobDefinition{Query: "*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if ids := server.JobIds(); len(ids) != 0 {
+		t.Errorf("JobIds() = %v after the timeout", ids)
+	}
+	if _, err := c.GetSearchJobStatus(ctx, jobId); !client.IsNotFound(err) {
+		t.Errorf("status of expired job: err = %v, want 404", err)
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	_, c := newTestServer(t, fakeserver.Config{AccessID: "id", AccessKey: "key"})
+	if _, _, err := c.CreateSearchJob(context.Background(), client.SearchJobDefinition{Query: "*"}); err != nil {
+		t.Errorf("create with valid credentials: %v", err)
+	}
+
+	server := fakeserver.New(fakeserver.Config{AccessID: "id", AccessKey: "key"})
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+	wrong, err := client.New(client.Config{AccessID: "id", AccessKey: "wrong", Endpoint: srv.URL + "/api", RateLimit: &client.RateLimit{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = wrong.CreateSearchJob(context.Background(), client.SearchJobDefinition{Query: "*"})
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("create with a wrong key: err = %v, want 401", err)
+	}
+}
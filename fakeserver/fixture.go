@@ -0,0 +1,122 @@
+package fakeserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Field describes a column in a messages or records response.
+type Field struct {
+	Name      string `json:"name"`
+	FieldType string `json:"fieldType,omitempty"`
+	KeyField  bool   `json:"keyField,omitempty"`
+}
+
+// Step is one stage of a scripted search job life cycle.
+type Step struct {
+	// State is reported by status requests while the step is active, e.g.
+	// "GATHERING RESULTS", "DONE GATHERING RESULTS", "FORCE PAUSED" or
+	// "CANCELLED".
+	State string `json:"state"`
+	// Polls is how many status requests the step lasts. The last step of a
+	// scenario lasts forever. Defaults to 1.
+	Polls int `json:"polls,omitempty"`
+}
+
+// HistogramBucket is a status histogram entry.
+type HistogramBucket struct {
+	StartTimestamp int64 `json:"startTimestamp"`
+	Length         int64 `json:"length"`
+	Count          int64 `json:"count"`
+}
+
+// Scenario is the scripted behaviour of search jobs whose query matches.
+type Scenario struct {
+	// Match selects the scenario for queries containing it. An empty Match
+	// matches every query.
+	Match string `json:"match,omitempty"`
+	// States is the sequence of states reported by status requests. While a
+	// job is not done, only a share of the messages and records proportional
+	// to its progress through the steps is visible.
+	States   []Step              `json:"states,omitempty"`
+	Fields   []Field             `json:"fields,omitempty"`
+	Messages []map[string]string `json:"messages,omitempty"`
+	// ByTime limits the messages of each job to those whose _messagetime, in
+	// epoch milliseconds, falls within the job's from and to.
+	ByTime bool `json:"byTime,omitempty"`
+	// MessageLimit, if set, caps the messages of each job. A job with more
+	// messages is FORCE PAUSED with the first MessageLimit of them, as the
+	// API does at its result limit.
+	MessageLimit     int                 `json:"messageLimit,omitempty"`
+	RecordFields     []Field             `json:"recordFields,omitempty"`
+	Records          []map[string]string `json:"records,omitempty"`
+	HistogramBuckets []HistogramBucket   `json:"histogramBuckets,omitempty"`
+	PendingErrors    []string            `json:"pendingErrors,omitempty"`
+	PendingWarnings  []string            `json:"pendingWarnings,omitempty"`
+}
+
+// Fault makes the server answer matching requests with an error status.
+type Fault struct {
+	// Op is one of create, status, messages, records, delete or * for all.
+	Op string `json:"op"`
+	// Status is the HTTP status code to return, e.g. 429 or 500.
+	Status int `json:"status"`
+	// Count is how many requests fail before the fault clears. Zero means
+	// every matching request fails.
+	Count int `json:"count,omitempty"`
+	// After is how many matching requests succeed before the fault starts,
+	// e.g. to fail an export midway.
+	After int `json:"after,omitempty"`
+	// RetryAfter, if set, is sent as the Retry-After header.
+	RetryAfter string `json:"retryAfter,omitempty"`
+}
+
+// ParseFault parses a fault from OP:STATUS[:COUNT], e.g. "messages:429:2".
+func ParseFault(spec string) (Fault, error) {
+	var fault Fault
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return fault, fmt.Errorf("invalid fault %q: expected OP:STATUS[:COUNT]", spec)
+	}
+	fault.Op = parts[0]
+	if _, err := fmt.Sscan(parts[1], &fault.Status); err != nil {
+		return fault, fmt.Errorf("invalid fault status in %q", spec)
+	}
+	if len(parts) == 3 {
+		if _, err := fmt.Sscan(parts[2], &fault.Count); err != nil {
+			return fault, fmt.Errorf("invalid fault count in %q", spec)
+		}
+	}
+	return fault, nil
+}
+
+// Fixture is the content of a fixture file.
+type Fixture struct {
+	Scenarios []Scenario `json:"scenarios"`
+	Faults    []Fault    `json:"faults,omitempty"`
+}
+
+// LoadFixture reads a fixture file.
+func LoadFixture(path string) (Fixture, error) {
+	var fixture Fixture
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fixture, err
+	}
+	if err := json.Unmarshal(content, &fixture); err != nil {
+		return fixture, fmt.Errorf("%s: %w", path, err)
+	}
+	return fixture, nil
+}
+
+// scenario returns the first scenario matching query.
+func (f Fixture) scenario(query string) Scenario {
+	for _, scenario := range f.Scenarios {
+		if strings.Contains(query, scenario.Match) {
+			return scenario
+		}
+	}
+	return Scenario{}
+}
@@ -0,0 +1,2 @@
+// Package filelock serializes access to files shared between processes.
+package filelock
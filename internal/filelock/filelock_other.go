@@ -0,0 +1,18 @@
+//go:build !unix
+
+package filelock
+
+import "os"
+
+// File locking is not available here, so processes only share files on a
+// best-effort basis.
+
+// Lock is a no-op on this platform.
+func Lock(file *os.File) error {
+	return nil
+}
+
+// Unlock is a no-op on this platform.
+func Unlock(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,211 @@
+// Package queries stores named search queries, with their default time
+// windows, auto-parsing modes and template variables, as YAML files in a
+// query library directory.
+package queries
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// ProjectDir is the project-local query library, relative to a project root.
+const ProjectDir = ".sumo/queries"
+
+// ErrNotFound is returned when no library holds a query of the given name.
+var ErrNotFound = errors.New("query not found")
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Query is a saved search. Its fields use the keys of job definitions, and
+// any of its strings may contain template placeholders.
+type Query struct {
+	Name            string            `yaml:"-"`
+	Description     string            `yaml:"description,omitempty"`
+	Query           string            `yaml:"query"`
+	From            string            `yaml:"from,omitempty"`
+	To              string            `yaml:"to,omitempty"`
+	Duration        string            `yaml:"duration,omitempty"`
+	TimeZone        string            `yaml:"timeZone,omitempty"`
+	ByReceiptTime   *bool             `yaml:"byReceiptTime,omitempty"`
+	AutoParsingMode string            `yaml:"autoParsingMode,omitempty"`
+	Vars            map[string]string `yaml:"vars,omitempty"`
+
+	// Path is the file the query was loaded from.
+	Path string `yaml:"-"`
+}
+
+// Library is a list of query directories. Lookups search them in order, so
+// a query in an earlier directory shadows one of the same name in a later
+// directory.
+type Library struct {
+	dirs []string
+}
+
+// Open returns the library made of dirs. Directories are created when a
+// query is first saved to them.
+func Open(dirs ...string) *Library {
+	return &Library{dirs: dirs}
+}
+
+// Dirs returns the directories of the library, in lookup order.
+func (l *Library) Dirs() []string {
+	return l.dirs
+}
+
+// FindProjectDir returns the project-local query directory of start or its
+// nearest parent that has one.
+func FindProjectDir(start string) (string, bool) {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return "", false
+	}
+	for {
+		candidate := filepath.Join(dir, ProjectDir)
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return candidate, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// ValidateName checks that name can be used as a file name.
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid query name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return nil
+}
+
+// Load returns the query called name from the first directory holding it.
+func (l *Library) Load(name string) (*Query, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	for _, dir := range l.dirs {
+		query, err := read(filepath.Join(dir, name+".yaml"))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		query.Name = name
+		return query, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+// Save writes query to dir. An existing query of the same name is only
+// replaced when overwrite is set.
+func (l *Library) Save(dir string, query Query, overwrite bool) (string, error) {
+	if err := ValidateName(query.Name); err != nil {
+		return "", err
+	}
+	if len(strings.TrimSpace(query.Query)) == 0 {
+		return "", fmt.Errorf("a query is required")
+	}
+	path := filepath.Join(dir, query.Name+".yaml")
+	if _, err := os.Stat(path); err == nil && !overwrite {
+		return "", fmt.Errorf("query %s already exists in %s", query.Name, dir)
+	}
+	var content bytes.Buffer
+	encoder := yaml.NewEncoder(&content)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(query); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	temp, err := os.CreateTemp(dir, "."+query.Name+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(content.Bytes()); err != nil {
+		temp.Close()
+		return "", err
+	}
+	if err := temp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Remove deletes the query called name from the first directory holding it,
+// and returns its path.
+func (l *Library) Remove(name string) (string, error) {
+	query, err := l.Load(name)
+	if err != nil {
+		return "", err
+	}
+	return query.Path, os.Remove(query.Path)
+}
+
+// List returns the queries of all directories sorted by name, leaving out
+// shadowed ones. Files that can't be read are reported in the error, after
+// the others have been listed.
+func (l *Library) List() ([]Query, error) {
+	seen := make(map[string]bool)
+	var list []Query
+	var errs []error
+	for _, dir := range l.dirs {
+		paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			name := strings.TrimSuffix(filepath.Base(path), ".yaml")
+			if seen[name] || ValidateName(name) != nil {
+				continue
+			}
+			seen[name] = true
+			query, err := read(path)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			query.Name = name
+			list = append(list, *query)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list, errors.Join(errs...)
+}
+
+// read decodes a query file. Unknown keys are rejected so that typos don't
+// silently change the search.
+func read(path string) (*Query, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var query Query
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&query); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	query.Path = path
+	return &query, nil
+}
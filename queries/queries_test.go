@@ -0,0 +1,156 @@
+package queries_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nhoag/sumo-search-job-cli/queries"
+)
+
+// writeQuery writes a query file with content to dir.
+func writeQuery(t *testing.T, dir string, name string, content string) string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name+".yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFindProjectDir(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "project")
+	nested := filepath.Join(project, "services", "api")
+	outside := filepath.Join(root, "elsewhere")
+	for _, dir := range []string{filepath.Join(project, queries.ProjectDir), nested, outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A file of the library's name is not a library.
+	if err := os.MkdirAll(filepath.Join(outside, ".sumo"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, queries.ProjectDir), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := filepath.Join(project, queries.ProjectDir)
+	for _, start := range []string{project, nested} {
+		dir, ok := queries.FindProjectDir(start)
+		if !ok || dir != want {
+			t.Errorf("FindProjectDir(%s) = %s, %t, want %s", start, dir, ok, want)
+		}
+	}
+	if dir, ok := queries.FindProjectDir(outside); ok {
+		t.Errorf("FindProjectDir(%s) = %s, want none", outside, dir)
+	}
+}
+
+func TestLibraryShadowing(t *testing.T) {
+	root := t.TempDir()
+	projectDir := filepath.Join(root, "project", queries.ProjectDir)
+	userDir := filepath.Join(root, "user")
+	projectPath := writeQuery(t, projectDir, "errors", "query: error | count by _sourcehost\nduration: 1h\n")
+	writeQuery(t, userDir, "errors", "query: error\n")
+	writeQuery(t, userDir, "logins", "query: login\ndescription: Logins\n")
+	library := queries.Open(projectDir, userDir)
+
+	query, err := library.Load("errors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.Query != "error | count by _sourcehost" || query.Duration != "1h" || query.Path != projectPath {
+		t.Errorf("Load(errors) = %+v, want the project query", query)
+	}
+	query, err = library.Load("logins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.Name != "logins" || query.Description != "Logins" {
+		t.Errorf("Load(logins) = %+v, want the user query", query)
+	}
+	if _, err := library.Load("missing"); !errors.Is(err, queries.ErrNotFound) {
+		t.Errorf("Load(missing) error = %v, want %v", err, queries.ErrNotFound)
+	}
+
+	list, err := library.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, q := range list {
+		names = append(names, q.Name+"="+q.Query)
+	}
+	if got, want := strings.Join(names, ","), "errors=error | count by _sourcehost,logins=login"; got != want {
+		t.Errorf("List() = %s, want %s", got, want)
+	}
+
+	// Removing the project query uncovers the user query.
+	path, err := library.Remove("errors")
+	if err != nil || path != projectPath {
+		t.Fatalf("Remove(errors) = %s, %v, want %s", path, err, projectPath)
+	}
+	if query, err := library.Load("errors"); err != nil || query.Query != "error" {
+		t.Errorf("Load(errors) after Remove = %+v, %v, want the user query", query, err)
+	}
+}
+
+func TestLibraryKnownFields(t *testing.T) {
+	dir := t.TempDir()
+	writeQuery(t, dir, "typo", "query: error\ndurration: 1h\n")
+	writeQuery(t, dir, "valid", "query: error\n")
+	library := queries.Open(dir)
+
+	if _, err := library.Load("typo"); err == nil || !strings.Contains(err.Error(), "field durration not found") {
+		t.Errorf("Load(typo) error = %v, want an unknown field error", err)
+	}
+	// List keeps the readable queries and reports the others.
+	list, err := library.List()
+	if err == nil || !strings.Contains(err.Error(), "typo.yaml") {
+		t.Errorf("List() error = %v, want an error for typo.yaml", err)
+	}
+	if len(list) != 1 || list[0].Name != "valid" {
+		t.Errorf("List() = %+v, want only valid", list)
+	}
+}
+
+func TestLibrarySave(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "queries")
+	library := queries.Open(dir)
+	receipt := true
+	query := queries.Query{Name: "errors", Query: "error", Duration: "15m", ByReceiptTime: &receipt, Vars: map[string]string{"host": "web"}}
+	path, err := library.Save(dir, query, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := library.Load("errors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Path != path || loaded.Query != "error" || loaded.Duration != "15m" || !*loaded.ByReceiptTime || loaded.Vars["host"] != "web" {
+		t.Errorf("Load() = %+v, want the saved query", loaded)
+	}
+	if _, err := library.Save(dir, query, false); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Save() of an existing query error = %v, want already exists", err)
+	}
+	query.Query = "error | count"
+	if _, err := library.Save(dir, query, true); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := library.Load("errors"); err != nil || loaded.Query != "error | count" {
+		t.Errorf("Load() after overwrite = %+v, %v", loaded, err)
+	}
+
+	for _, invalid := range []queries.Query{{Name: "../escape", Query: "error"}, {Name: ".hidden", Query: "error"}, {Name: "empty", Query: " "}} {
+		if _, err := library.Save(dir, invalid, false); err == nil {
+			t.Errorf("Save(%+v) succeeded", invalid)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Package registry keeps a local record of the search jobs created by the
+// CLI, so that jobs which were never deleted can be found and cleaned up.
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/nhoag/sumo-search-job-cli/internal/filelock"
+)
+
+// Job is a search job recorded in the registry.
+type Job struct {
+	ID         string    `json:"id"`
+	Location   string    `json:"location,omitempty"`
+	Query      string    `json:"query"`
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	TimeZone   string    `json:"timeZone,omitempty"`
+	Endpoint   string    `json:"endpoint"`
+	Deployment string    `json:"deployment,omitempty"`
+	Profile    string    `json:"profile,omitempty"`
+	Created    time.Time `json:"created"`
+}
+
+// Registry is a JSON file of jobs. Updates take an exclusive lock on the
+// file, so concurrent processes may share one registry.
+type Registry struct {
+	path string
+}
+
+// Open returns the registry stored at path. The file is created on the first
+// update.
+func Open(path string) *Registry {
+	return &Registry{path: path}
+}
+
+// Path returns the registry file.
+func (r *Registry) Path() string {
+	return r.path
+}
+
+// Add records job, replacing any earlier entry with the same ID.
+func (r *Registry) Add(job Job) error {
+	return r.update(func(jobs map[string]Job) {
+		jobs[job.ID] = job
+	})
+}
+
+// Remove forgets the given jobs. Unknown IDs are ignored.
+func (r *Registry) Remove(ids ...string) error {
+	return r.update(func(jobs map[string]Job) {
+		for _, id := range ids {
+			delete(jobs, id)
+		}
+	})
+}
+
+// List returns the recorded jobs, oldest first.
+func (r *Registry) List() ([]Job, error) {
+	file, err := os.Open(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("job registry: %w", err)
+	}
+	defer file.Close()
+	if err := filelock.Lock(file); err != nil {
+		return nil, fmt.Errorf("job registry: %w", err)
+	}
+	defer filelock.Unlock(file)
+
+	jobs, err := read(file)
+	if err != nil {
+		return nil, err
+	}
+	return sorted(jobs), nil
+}
+
+// update applies change to the registry under the file lock.
+func (r *Registry) update(change func(map[string]Job)) error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0700); err != nil {
+		return fmt.Errorf("job registry: %w", err)
+	}
+	file, err := os.OpenFile(r.path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("job registry: %w", err)
+	}
+	defer file.Close()
+	if err := filelock.Lock(file); err != nil {
+		return fmt.Errorf("job registry: %w", err)
+	}
+	defer filelock.Unlock(file)
+
+	jobs, err := read(file)
+	if err != nil {
+		return err
+	}
+	change(jobs)
+	content, err := json.MarshalIndent(sorted(jobs), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := file.Truncate(0); err != nil {
+		return fmt.Errorf("job registry: %w", err)
+	}
+	if _, err := file.WriteAt(append(content, '\n'), 0); err != nil {
+		return fmt.Errorf("job registry: %w", err)
+	}
+	return nil
+}
+
+func read(file *os.File) (map[string]Job, error) {
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("job registry: %w", err)
+	}
+	jobs := make(map[string]Job)
+	if len(content) == 0 {
+		return jobs, nil
+	}
+	var list []Job
+	if err := json.Unmarshal(content, &list); err != nil {
+		return nil, fmt.Errorf("job registry %s: %w", file.Name(), err)
+	}
+	for _, job := range list {
+		jobs[job.ID] = job
+	}
+	return jobs, nil
+}
+
+func sorted(jobs map[string]Job) []Job {
+	list := make([]Job, 0, len(jobs))
+	for _, job := range jobs {
+		list = append(list, job)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Created.Equal(list[j].Created) {
+			return list[i].ID < list[j].ID
+		}
+		return list[i].Created.Before(list[j].Created)
+	})
+	return list
+}
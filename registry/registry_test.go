@@ -0,0 +1,118 @@
+package registry_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nhoag/sumo-search-job-cli/registry"
+)
+
+// ids returns the IDs of jobs in order.
+func ids(jobs []registry.Job) string {
+	var list []string
+	for _, job := range jobs {
+		list = append(list, job.ID)
+	}
+	return strings.Join(list, ",")
+}
+
+func TestRegistry(t *testing.T) {
+	r := registry.Open(filepath.Join(t.TempDir(), "sumo", "jobs.json"))
+	if jobs, err := r.List(); err != nil || len(jobs) != 0 {
+		t.Fatalf("List() of a new registry = %v, %v", jobs, err)
+	}
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, job := range []registry.Job{
+		{ID: "C", Query: "c", Created: created.Add(2 * time.Minute)},
+		{ID: "B", Query: "b", Created: created},
+		{ID: "A", Query: "a", Created: created},
+		{ID: "D", Query: "d", Created: created.Add(time.Minute)},
+	} {
+		if err := r.Add(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	jobs, err := r.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Oldest first, then by ID.
+	if got := ids(jobs); got != "A,B,D,C" {
+		t.Errorf("List() = %s, want A,B,D,C", got)
+	}
+
+	// Adding a known ID replaces its entry.
+	if err := r.Add(registry.Job{ID: "A", Query: "a2", Created: created.Add(3 * time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Remove("B", "unknown"); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err = r.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(jobs); got != "D,C,A" {
+		t.Errorf("List() = %s, want D,C,A", got)
+	}
+	if jobs[2].Query != "a2" {
+		t.Errorf("job A has query %q, want a2", jobs[2].Query)
+	}
+
+	info, err := os.Stat(r.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("registry mode %o, want 600", perm)
+	}
+}
+
+func TestRegistryConcurrentUpdates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Each update opens the file on its own, as separate
+			// processes do.
+			errs <- registry.Open(path).Add(registry.Job{ID: fmt.Sprintf("JOB%02d", i), Created: time.Unix(int64(i), 0)})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	jobs, err := registry.Open(path).List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != n {
+		t.Errorf("registry has %d jobs, want %d", len(jobs), n)
+	}
+}
+
+func TestRegistryCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	if err := os.WriteFile(path, []byte("[{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	r := registry.Open(path)
+	if _, err := r.List(); err == nil || !strings.Contains(err.Error(), "job registry") {
+		t.Errorf("List() error = %v, want a job registry error", err)
+	}
+	if err := r.Add(registry.Job{ID: "A"}); err == nil {
+		t.Error("Add() to a corrupt registry succeeded")
+	}
+}
@@ -0,0 +1,249 @@
+// Package timeexpr parses the time expressions accepted for search windows.
+//
+// A point in time may be given as:
+//
+//   - RFC 3339, with or without an offset: 2022-02-01T00:00:00Z,
+//     2022-02-01T00:00:00-05:00, 2022-02-01T00:00:00, or a date 2022-02-01.
+//     Times without an offset are in the given location.
+//   - Epoch seconds (up to 11 digits) or milliseconds: 1643673600,
+//     1643673600000.
+//   - A time relative to now, optionally snapped down to a unit: now, -15m,
+//     now-2h, +1d, now-1d@d, @h. Offsets accept Go durations plus d (days)
+//     and w (weeks); snap units are s, m, h, d, w (Monday) and mon.
+//   - A calendar preset: today, yesterday, this-week or last-week, meaning
+//     the start of that period.
+//
+// Calendar days and weeks are evaluated in the given location.
+package timeexpr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layout is the local time layout of search job definitions.
+const Layout = "2006-01-02T15:04:05"
+
+var (
+	epochPattern    = regexp.MustCompile(`^\d+$`)
+	relativePattern = regexp.MustCompile(`^(now)?([+-][0-9a-z.+-]+)?(@[a-z]+)?$`)
+	durationPattern = regexp.MustCompile(`(\d+(?:\.\d+)?)([a-zµ]+)`)
+)
+
+// Parse evaluates expr relative to now in loc.
+func Parse(expr string, now time.Time, loc *time.Location) (time.Time, error) {
+	expr = strings.TrimSpace(expr)
+	now = now.In(loc)
+	if len(expr) == 0 {
+		return time.Time{}, fmt.Errorf("empty time expression")
+	}
+	if start, _, ok := preset(expr, now); ok {
+		return start, nil
+	}
+	if epochPattern.MatchString(expr) {
+		value, err := strconv.ParseInt(expr, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid epoch time %q", expr)
+		}
+		if len(expr) <= 11 {
+			return time.Unix(value, 0).In(loc), nil
+		}
+		return time.UnixMilli(value).In(loc), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, expr); err == nil {
+		return t.In(loc), nil
+	}
+	for _, layout := range []string{Layout, "2006-01-02T15:04:05.999999999", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, expr, loc); err == nil {
+			return t, nil
+		}
+	}
+	if match := relativePattern.FindStringSubmatch(strings.ToLower(expr)); match != nil {
+		t := now
+		if len(match[2]) > 0 {
+			offset, err := ParseDuration(match[2])
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid time expression %q: %w", expr, err)
+			}
+			t = t.Add(offset)
+		}
+		if len(match[3]) > 0 {
+			snapped, err := snap(t, match[3][1:])
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid time expression %q: %w", expr, err)
+			}
+			t = snapped
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time expression %q", expr)
+}
+
+// ParseDuration parses a signed duration. Besides the units of
+// time.ParseDuration it accepts d for days and w for weeks, e.g. 1d12h.
+func ParseDuration(value string) (time.Duration, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return d, nil
+	}
+	sign := time.Duration(1)
+	rest := value
+	switch {
+	case strings.HasPrefix(rest, "-"):
+		sign, rest = -1, rest[1:]
+	case strings.HasPrefix(rest, "+"):
+		rest = rest[1:]
+	}
+	if len(rest) == 0 || len(durationPattern.ReplaceAllString(rest, "")) > 0 {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	var total time.Duration
+	for _, match := range durationPattern.FindAllStringSubmatch(rest, -1) {
+		var unit time.Duration
+		switch match[2] {
+		case "d":
+			unit = 24 * time.Hour
+		case "w":
+			unit = 7 * 24 * time.Hour
+		default:
+			d, err := time.ParseDuration(match[0])
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", value)
+			}
+			total += d
+			continue
+		}
+		amount, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		total += time.Duration(amount * float64(unit))
+	}
+	return sign * total, nil
+}
+
+// Window resolves the from, to and duration expressions of a search into a
+// time range. Any one of them may be empty:
+//
+//   - to defaults to now, or to the end of the period when from is a
+//     calendar preset;
+//   - a duration counts back from to, or forward from from;
+//   - from, to and duration can't all be given.
+//
+// A calendar preset given as to means the end of that period. Ends in the
+// future are capped at now.
+func Window(from string, to string, duration string, now time.Time, loc *time.Location) (time.Time, time.Time, error) {
+	now = now.In(loc)
+	if len(from) > 0 && len(to) > 0 && len(duration) > 0 {
+		return time.Time{}, time.Time{}, fmt.Errorf("from, to and duration can't all be given")
+	}
+	var span time.Duration
+	if len(duration) > 0 {
+		d, err := ParseDuration(duration)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		// The sign is not significant: -3h and 3h both span three hours.
+		if d < 0 {
+			d = -d
+		}
+		if d == 0 {
+			return time.Time{}, time.Time{}, fmt.Errorf("duration must not be zero")
+		}
+		span = d
+	}
+
+	var start, end time.Time
+	var err error
+	if len(to) > 0 {
+		if _, presetEnd, ok := preset(strings.TrimSpace(to), now); ok {
+			end = presetEnd
+		} else if end, err = Parse(to, now, loc); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	if len(from) > 0 {
+		presetStart, presetEnd, ok := preset(strings.TrimSpace(from), now)
+		if ok {
+			start = presetStart
+			if len(to) == 0 && span == 0 {
+				end = presetEnd
+			}
+		} else if start, err = Parse(from, now, loc); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+
+	switch {
+	case len(from) > 0 && span > 0:
+		end = start.Add(span)
+	case len(from) == 0 && span > 0:
+		if end.IsZero() {
+			end = now
+		}
+		start = end.Add(-span)
+	case len(from) == 0:
+		return time.Time{}, time.Time{}, fmt.Errorf("a from time or a duration is required")
+	}
+	if end.IsZero() || end.After(now) {
+		end = now
+	}
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from %s is not before to %s", start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+	return start, end, nil
+}
+
+// IsPreset reports whether expr is a calendar preset such as yesterday.
+func IsPreset(expr string) bool {
+	_, _, ok := preset(strings.TrimSpace(expr), time.Now())
+	return ok
+}
+
+// preset returns the period named by a calendar preset.
+func preset(name string, now time.Time) (time.Time, time.Time, bool) {
+	today := startOfDay(now)
+	week := startOfWeek(now)
+	switch strings.ToLower(name) {
+	case "today":
+		return today, today.AddDate(0, 0, 1), true
+	case "yesterday":
+		return today.AddDate(0, 0, -1), today, true
+	case "this-week":
+		return week, week.AddDate(0, 0, 7), true
+	case "last-week":
+		return week.AddDate(0, 0, -7), week, true
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// snap truncates t to the start of unit in t's location.
+func snap(t time.Time, unit string) (time.Time, error) {
+	switch unit {
+	case "s":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, t.Location()), nil
+	case "m":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, t.Location()), nil
+	case "h":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location()), nil
+	case "d":
+		return startOfDay(t), nil
+	case "w":
+		return startOfWeek(t), nil
+	case "mon":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()), nil
+	}
+	return time.Time{}, fmt.Errorf("unknown snap unit %q (valid: s, m, h, d, w, mon)", unit)
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// startOfWeek returns the start of the Monday of t's week.
+func startOfWeek(t time.Time) time.Time {
+	day := startOfDay(t)
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
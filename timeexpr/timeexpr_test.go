@@ -0,0 +1,267 @@
+package timeexpr_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nhoag/sumo-search-job-cli/timeexpr"
+)
+
+// now is Wednesday 2024-03-13 10:20:30.5 UTC.
+var now = time.Date(2024, 3, 13, 10, 20, 30, 500000000, time.UTC)
+
+func newYork(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+	return loc
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		// Up to 11 digits are epoch seconds, longer ones milliseconds.
+		{"1643673600", time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"1643673600000", time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"1643673600123", time.Date(2022, 2, 1, 0, 0, 0, 123000000, time.UTC)},
+		{"16436736000", time.Unix(16436736000, 0).UTC()},
+		{"164367360000", time.UnixMilli(164367360000).UTC()},
+
+		{"2022-02-01T00:00:00Z", time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"2022-02-01T00:00:00-05:00", time.Date(2022, 2, 1, 5, 0, 0, 0, time.UTC)},
+		{"2022-02-01T00:00:00.25Z", time.Date(2022, 2, 1, 0, 0, 0, 250000000, time.UTC)},
+		{"2022-02-01T00:00:00", time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"2022-02-01 08:30:00", time.Date(2022, 2, 1, 8, 30, 0, 0, time.UTC)},
+		{"2022-02-01", time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)},
+
+		{"now", now},
+		{"NOW", now},
+		{"-15m", now.Add(-15 * time.Minute)},
+		{"now-2h", now.Add(-2 * time.Hour)},
+		{"+1d", now.Add(24 * time.Hour)},
+		{"now-1d12h", now.Add(-36 * time.Hour)},
+		{"@s", time.Date(2024, 3, 13, 10, 20, 30, 0, time.UTC)},
+		{"@m", time.Date(2024, 3, 13, 10, 20, 0, 0, time.UTC)},
+		{"@h", time.Date(2024, 3, 13, 10, 0, 0, 0, time.UTC)},
+		{"now-1d@d", time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC)},
+		{"@w", time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)},
+		{"-1w@w", time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)},
+		{"@mon", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+
+		{"today", time.Date(2024, 3, 13, 0, 0, 0, 0, time.UTC)},
+		{"yesterday", time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC)},
+		{"this-week", time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)},
+		{"last-week", time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := timeexpr.Parse(tt.expr, now, time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("got %s, want %s", got.Format(time.RFC3339Nano), tt.want.Format(time.RFC3339Nano))
+			}
+		})
+	}
+}
+
+func TestParseInLocation(t *testing.T) {
+	loc := newYork(t)
+	tests := []struct {
+		expr string
+		want string
+	}{
+		// Times without an offset are in the location, those with one keep
+		// their instant.
+		{"2022-02-01T00:00:00", "2022-02-01T00:00:00-05:00"},
+		{"2022-07-01T00:00:00", "2022-07-01T00:00:00-04:00"},
+		{"2022-02-01T00:00:00Z", "2022-01-31T19:00:00-05:00"},
+		{"1643673600", "2022-01-31T19:00:00-05:00"},
+		// Snaps and presets use the calendar of the location.
+		{"@d", "2024-03-13T00:00:00-04:00"},
+		{"yesterday", "2024-03-12T00:00:00-04:00"},
+		{"this-week", "2024-03-11T00:00:00-04:00"},
+		// The week before spans the start of daylight saving time.
+		{"last-week", "2024-03-04T00:00:00-05:00"},
+		{"now-3d@d", "2024-03-10T00:00:00-05:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := timeexpr.Parse(tt.expr, now, loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := got.Format(time.RFC3339); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{"", "  ", "soon", "now-", "now-5q", "@x", "-1h@fortnight", "2022-13-01", "99999999999999999999"} {
+		t.Run(expr, func(t *testing.T) {
+			if got, err := timeexpr.Parse(expr, now, time.UTC); err == nil {
+				t.Errorf("got %s, want an error", got)
+			}
+		})
+	}
+}
+
+// Job definitions hold whole seconds, so the milliseconds of an epoch are
+// dropped when the time is formatted with Layout.
+func TestParseEpochMillisLayout(t *testing.T) {
+	got, err := timeexpr.Parse("1643673600999", now, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Nanosecond() != 999000000 {
+		t.Errorf("got %d ns, want the milliseconds to be parsed", got.Nanosecond())
+	}
+	if formatted := got.Format(timeexpr.Layout); formatted != "2022-02-01T00:00:00" {
+		t.Errorf("got %s, want 2022-02-01T00:00:00", formatted)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"90s", 90 * time.Second},
+		{"1h30m", 90 * time.Minute},
+		{"-15m", -15 * time.Minute},
+		{"1d", 24 * time.Hour},
+		{"1d12h", 36 * time.Hour},
+		{"1.5d", 36 * time.Hour},
+		{"2w", 14 * 24 * time.Hour},
+		{"+1w1d", 8 * 24 * time.Hour},
+		{"-1d1h30m", -(25*time.Hour + 30*time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := timeexpr.ParseDuration(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+	for _, value := range []string{"", "-", "d", "1", "1x", "1d 2h", "1d-2h", "one day"} {
+		t.Run("invalid "+value, func(t *testing.T) {
+			if got, err := timeexpr.ParseDuration(value); err == nil {
+				t.Errorf("got %s, want an error", got)
+			}
+		})
+	}
+}
+
+func TestWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     string
+		to       string
+		duration string
+		want     [2]string
+	}{
+		{"from only", "2024-03-13T08:00:00", "", "", [2]string{"2024-03-13T08:00:00Z", "2024-03-13T10:20:30Z"}},
+		{"from and to", "-2h", "-1h", "", [2]string{"2024-03-13T08:20:30Z", "2024-03-13T09:20:30Z"}},
+		{"duration only", "", "", "3h", [2]string{"2024-03-13T07:20:30Z", "2024-03-13T10:20:30Z"}},
+		{"negative duration", "", "", "-3h", [2]string{"2024-03-13T07:20:30Z", "2024-03-13T10:20:30Z"}},
+		{"from and duration", "2024-03-12T00:00:00", "", "6h", [2]string{"2024-03-12T00:00:00Z", "2024-03-12T06:00:00Z"}},
+		{"to and duration", "", "2024-03-12T12:00:00", "1d", [2]string{"2024-03-11T12:00:00Z", "2024-03-12T12:00:00Z"}},
+		{"to in the future", "-1h", "+1d", "", [2]string{"2024-03-13T09:20:30Z", "2024-03-13T10:20:30Z"}},
+		{"from and duration past now", "-1h", "", "1d", [2]string{"2024-03-13T09:20:30Z", "2024-03-13T10:20:30Z"}},
+		{"preset from", "yesterday", "", "", [2]string{"2024-03-12T00:00:00Z", "2024-03-13T00:00:00Z"}},
+		{"preset to", "last-week", "yesterday", "", [2]string{"2024-03-04T00:00:00Z", "2024-03-13T00:00:00Z"}},
+		{"preset and duration", "yesterday", "", "2h", [2]string{"2024-03-12T00:00:00Z", "2024-03-12T02:00:00Z"}},
+		{"current preset", "today", "", "", [2]string{"2024-03-13T00:00:00Z", "2024-03-13T10:20:30Z"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := timeexpr.Window(tt.from, tt.to, tt.duration, now, time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := [2]string{start.Format(time.RFC3339), end.Format(time.RFC3339)}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Presets cover whole calendar days in the location, which are 23 hours
+// long when daylight saving time starts.
+func TestWindowPresetAcrossDST(t *testing.T) {
+	loc := newYork(t)
+	// Monday 2024-03-11, the day after clocks moved forward.
+	monday := time.Date(2024, 3, 11, 12, 0, 0, 0, loc)
+	tests := []struct {
+		from string
+		want [2]string
+		span time.Duration
+	}{
+		{"yesterday", [2]string{"2024-03-10T00:00:00-05:00", "2024-03-11T00:00:00-04:00"}, 23 * time.Hour},
+		{"last-week", [2]string{"2024-03-04T00:00:00-05:00", "2024-03-11T00:00:00-04:00"}, 7*24*time.Hour - time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from, func(t *testing.T) {
+			start, end, err := timeexpr.Window(tt.from, "", "", monday, loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := [2]string{start.Format(time.RFC3339), end.Format(time.RFC3339)}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if span := end.Sub(start); span != tt.span {
+				t.Errorf("got a span of %s, want %s", span, tt.span)
+			}
+		})
+	}
+}
+
+func TestWindowErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     string
+		to       string
+		duration string
+		want     string
+	}{
+		{"conflicting", "-2h", "-1h", "1h", "from, to and duration can't all be given"},
+		{"nothing", "", "", "", "a from time or a duration is required"},
+		{"to only", "", "-1h", "", "a from time or a duration is required"},
+		{"zero duration", "", "", "0s", "duration must not be zero"},
+		{"bad duration", "", "", "3 hours", "invalid duration"},
+		{"bad from", "soon", "", "", "invalid time expression"},
+		{"bad to", "-1h", "later", "", "invalid time expression"},
+		{"reversed", "-1h", "-2h", "", "is not before"},
+		{"future from", "+1h", "", "", "is not before"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := timeexpr.Window(tt.from, tt.to, tt.duration, now, time.UTC)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsPreset(t *testing.T) {
+	for expr, want := range map[string]bool{"today": true, " Yesterday ": true, "last-week": true, "now-1d@d": false, "2024-03-13": false} {
+		if got := timeexpr.IsPreset(expr); got != want {
+			t.Errorf("IsPreset(%q) = %t, want %t", expr, got, want)
+		}
+	}
+}